@@ -0,0 +1,230 @@
+package autocomplete
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+type Suggestion struct {
+	Text   string `json:"text"`
+	Field  string `json:"field"`
+	BookID int    `json:"book_id,omitempty"`
+}
+
+// entry is one way to reach a suggestion: its text starting from one of its
+// words, so "potter" also completes "Harry Potter".
+type entry struct {
+	key        string
+	wordIndex  int
+	suggestion Suggestion
+}
+
+// Index is an in-memory prefix index over book titles and authors. Entries
+// are kept sorted by key so a completion is a binary search plus a scan.
+type Index struct {
+	// loadMu is held from the query to the swap, so a slow load can not
+	// replace the entries with an older snapshot than a later one.
+	loadMu  sync.Mutex
+	mu      sync.RWMutex
+	entries []entry
+}
+
+type bookTitle struct {
+	ID    int    `db:"id"`
+	Title string `db:"title"`
+}
+
+func NewIndex() *Index {
+	return &Index{}
+}
+
+// Load rebuilds the index from the books table and swaps it in, so readers
+// never see a half built index.
+func (index *Index) Load(db *sqlx.DB) error {
+	index.loadMu.Lock()
+	defer index.loadMu.Unlock()
+
+	var books []bookTitle
+	err := db.Select(&books, `SELECT id, title FROM books`)
+	if err != nil {
+		return err
+	}
+
+	authors, err := loadAuthorEntries(db)
+	if err != nil {
+		return err
+	}
+
+	entries := bookEntries(books)
+	entries = append(entries, authors...)
+	sortEntries(entries)
+
+	index.mu.Lock()
+	index.entries = entries
+	index.mu.Unlock()
+
+	return nil
+}
+
+// Update re-reads the titles of the given books, dropping the ones that no
+// longer exist, and the author names, which are few compared to the books.
+// It is what a single change to the catalog needs instead of a full Load.
+func (index *Index) Update(db *sqlx.DB, bookIds ...int) error {
+	index.loadMu.Lock()
+	defer index.loadMu.Unlock()
+
+	var books []bookTitle
+	if len(bookIds) > 0 {
+		err := db.Select(&books, `SELECT id, title FROM books WHERE id = ANY($1)`, pq.Array(bookIds))
+		if err != nil {
+			return err
+		}
+	}
+
+	authors, err := loadAuthorEntries(db)
+	if err != nil {
+		return err
+	}
+
+	added := bookEntries(books)
+	added = append(added, authors...)
+	sortEntries(added)
+
+	changed := make(map[int]bool, len(bookIds))
+	for _, bookId := range bookIds {
+		changed[bookId] = true
+	}
+
+	index.mu.Lock()
+	defer index.mu.Unlock()
+
+	// Both sides are sorted, so merging keeps the index sorted.
+	entries := make([]entry, 0, len(index.entries)+len(added))
+	i := 0
+	for _, current := range index.entries {
+		if current.suggestion.Field == "author" || changed[current.suggestion.BookID] {
+			continue
+		}
+		for i < len(added) && added[i].key < current.key {
+			entries = append(entries, added[i])
+			i++
+		}
+		entries = append(entries, current)
+	}
+	entries = append(entries, added[i:]...)
+
+	index.entries = entries
+	return nil
+}
+
+// loadAuthorEntries returns the entries of the authors that have books, the
+// only ones worth completing.
+func loadAuthorEntries(db *sqlx.DB) ([]entry, error) {
+	var authors []string
+	err := db.Select(&authors, `
+		SELECT a.name FROM authors a
+		WHERE EXISTS (SELECT 1 FROM book_authors ba WHERE ba.author_id = a.id)
+	`)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []entry
+	for _, author := range authors {
+		entries = appendEntries(entries, Suggestion{Text: author, Field: "author"})
+	}
+	return entries, nil
+}
+
+func bookEntries(books []bookTitle) []entry {
+	var entries []entry
+	for _, book := range books {
+		entries = appendEntries(entries, Suggestion{Text: book.Title, Field: "title", BookID: book.ID})
+	}
+	return entries
+}
+
+func sortEntries(entries []entry) {
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].key < entries[j].key
+	})
+}
+
+// Complete returns up to limit suggestions whose title or author has a word
+// starting with prefix. Matches at the start of the text come first, then
+// shorter texts.
+func (index *Index) Complete(prefix string, limit int) []Suggestion {
+	prefix = normalize(prefix)
+	if prefix == "" {
+		return []Suggestion{}
+	}
+
+	index.mu.RLock()
+	defer index.mu.RUnlock()
+
+	start := sort.Search(len(index.entries), func(i int) bool {
+		return index.entries[i].key >= prefix
+	})
+
+	seen := make(map[string]int)
+	var matches []entry
+	for i := start; i < len(index.entries) && strings.HasPrefix(index.entries[i].key, prefix); i++ {
+		current := index.entries[i]
+
+		// Each book's title and each author should only show up once, with
+		// its best match.
+		dedupeKey := current.suggestion.Field + ":" + strconv.Itoa(current.suggestion.BookID) + ":" + strings.ToLower(current.suggestion.Text)
+
+		if position, ok := seen[dedupeKey]; ok {
+			if current.wordIndex < matches[position].wordIndex {
+				matches[position] = current
+			}
+			continue
+		}
+
+		seen[dedupeKey] = len(matches)
+		matches = append(matches, current)
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].wordIndex != matches[j].wordIndex {
+			return matches[i].wordIndex < matches[j].wordIndex
+		}
+		if len(matches[i].suggestion.Text) != len(matches[j].suggestion.Text) {
+			return len(matches[i].suggestion.Text) < len(matches[j].suggestion.Text)
+		}
+		return matches[i].suggestion.Text < matches[j].suggestion.Text
+	})
+
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+
+	suggestions := make([]Suggestion, len(matches))
+	for i, match := range matches {
+		suggestions[i] = match.suggestion
+	}
+
+	return suggestions
+}
+
+func appendEntries(entries []entry, suggestion Suggestion) []entry {
+	words := strings.Fields(normalize(suggestion.Text))
+	for i := range words {
+		entries = append(entries, entry{
+			key:        strings.Join(words[i:], " "),
+			wordIndex:  i,
+			suggestion: suggestion,
+		})
+	}
+	return entries
+}
+
+func normalize(text string) string {
+	return strings.Join(strings.Fields(strings.ToLower(text)), " ")
+}
@@ -0,0 +1,482 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/faqq11/lib-management/internal/autocomplete"
+	"github.com/faqq11/lib-management/internal/helper"
+	"github.com/faqq11/lib-management/internal/models"
+	"github.com/faqq11/lib-management/internal/models/response"
+	"github.com/gorilla/mux"
+	"github.com/jmoiron/sqlx"
+)
+
+type AuthorHandler struct {
+	DB           *sqlx.DB
+	Autocomplete *autocomplete.Index
+}
+
+const contributorRoleAuthor = "author"
+
+var validContributorRoles = map[string]bool{
+	contributorRoleAuthor: true,
+	"editor":              true,
+	"translator":          true,
+	"illustrator":         true,
+}
+
+var errAuthorNotFound = errors.New("Author not found")
+
+// bookAuthorDisplayColumn is the display string kept in books.author for book
+// b: its authors in order, without editors or translators.
+const bookAuthorDisplayColumn = `coalesce((
+	SELECT string_agg(a.name, ', ' ORDER BY ba.position)
+	FROM book_authors ba
+	JOIN authors a ON ba.author_id = a.id
+	WHERE ba.book_id = b.id AND ba.role = 'author'
+), '')`
+
+const authorSelectQuery = `
+		SELECT
+			a.id,
+			a.name,
+			a.bio,
+			(SELECT COUNT(DISTINCT ba.book_id) FROM book_authors ba WHERE ba.author_id = a.id) AS book_count,
+			a.created_at
+		FROM authors a
+`
+
+type authorInput struct {
+	Name string  `json:"name"`
+	Bio  *string `json:"bio"`
+}
+
+func (authorHandler *AuthorHandler) CreateAuthor(writer http.ResponseWriter, request *http.Request) {
+	var input authorInput
+
+	err := json.NewDecoder(request.Body).Decode(&input)
+	if err != nil {
+		log.Printf("CreateAuthor - JSON decode error: %v", err)
+		helper.ErrorResponse(writer, http.StatusBadRequest, "Invalid JSON format")
+		return
+	}
+
+	input.Name = strings.TrimSpace(input.Name)
+	if input.Name == "" {
+		helper.ErrorResponse(writer, http.StatusBadRequest, "Author name is required")
+		return
+	}
+
+	var authorId int
+	err = authorHandler.DB.Get(&authorId, `
+		INSERT INTO authors (name, bio)
+		VALUES ($1, $2)
+		RETURNING id
+	`, input.Name, input.Bio)
+	if err != nil {
+		if helper.IsUniqueViolation(err) {
+			helper.ErrorResponse(writer, http.StatusConflict, "An author with this name already exists")
+			return
+		}
+		log.Printf("CreateAuthor - Insert error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to create author")
+		return
+	}
+
+	helper.SuccessResponse(writer, http.StatusCreated, map[string]interface{}{
+		"message": "Author created successfully",
+		"id":      authorId,
+	})
+}
+
+func (authorHandler *AuthorHandler) GetAuthors(writer http.ResponseWriter, request *http.Request) {
+	pagination, err := helper.ParsePagination(request)
+	if err != nil {
+		helper.ErrorResponse(writer, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var args []interface{}
+	whereClause := ""
+	if name := request.URL.Query().Get("q"); name != "" {
+		whereClause = " WHERE a.name ILIKE $1"
+		args = append(args, "%"+name+"%")
+	}
+
+	var total int
+	err = authorHandler.DB.Get(&total, `SELECT COUNT(*) FROM authors a`+whereClause, args...)
+	if err != nil {
+		log.Printf("GetAuthors - Count error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to count authors")
+		return
+	}
+
+	argIndex := len(args) + 1
+	finalQuery := authorSelectQuery + whereClause + `
+		ORDER BY a.name, a.id
+		LIMIT $` + strconv.Itoa(argIndex) + ` OFFSET $` + strconv.Itoa(argIndex+1)
+	args = append(args, pagination.Limit, pagination.Offset())
+
+	authors := []response.AuthorResponse{}
+	err = authorHandler.DB.Select(&authors, finalQuery, args...)
+	if err != nil {
+		log.Printf("GetAuthors - Select error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to fetch authors")
+		return
+	}
+
+	next, prev := pagination.Links(request, total)
+
+	helper.SuccessResponse(writer, http.StatusOK, response.PaginatedResponse{
+		Data:       authors,
+		Page:       pagination.Page,
+		Limit:      pagination.Limit,
+		Total:      total,
+		TotalPages: pagination.TotalPages(total),
+		Next:       next,
+		Prev:       prev,
+	})
+}
+
+func (authorHandler *AuthorHandler) GetAuthorById(writer http.ResponseWriter, request *http.Request) {
+	authorId, ok := parseAuthorId(writer, request, "GetAuthorById")
+	if !ok {
+		return
+	}
+
+	var author response.AuthorResponse
+	err := authorHandler.DB.Get(&author, authorSelectQuery+` WHERE a.id = $1`, authorId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			helper.ErrorResponse(writer, http.StatusNotFound, "Author not found")
+			return
+		}
+
+		log.Printf("GetAuthorById - Database error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	helper.SuccessResponse(writer, http.StatusOK, author)
+}
+
+func (authorHandler *AuthorHandler) GetAuthorBooks(writer http.ResponseWriter, request *http.Request) {
+	authorId, ok := parseAuthorId(writer, request, "GetAuthorBooks")
+	if !ok {
+		return
+	}
+
+	pagination, err := helper.ParsePagination(request)
+	if err != nil {
+		helper.ErrorResponse(writer, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	args := []interface{}{authorId}
+	conditions := "ba.author_id = $1"
+	if role := request.URL.Query().Get("role"); role != "" {
+		if !validContributorRoles[role] {
+			helper.ErrorResponse(writer, http.StatusBadRequest, "Role must be one of author, editor, translator, illustrator")
+			return
+		}
+		conditions += " AND ba.role = $2"
+		args = append(args, role)
+	}
+
+	var exists bool
+	err = authorHandler.DB.Get(&exists, `SELECT EXISTS (SELECT 1 FROM authors WHERE id = $1)`, authorId)
+	if err != nil {
+		log.Printf("GetAuthorBooks - Author check error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+	if !exists {
+		helper.ErrorResponse(writer, http.StatusNotFound, "Author not found")
+		return
+	}
+
+	fromClause := bookFromClause + `
+		JOIN book_authors ba ON ba.book_id = b.id
+		WHERE ` + conditions
+
+	var total int
+	err = authorHandler.DB.Get(&total, `SELECT COUNT(*) `+fromClause, args...)
+	if err != nil {
+		log.Printf("GetAuthorBooks - Count error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to count books")
+		return
+	}
+
+	argIndex := len(args) + 1
+	finalQuery := `SELECT ` + bookSelectColumns + `,
+			ba.role` + fromClause + `
+		ORDER BY b.publication_year NULLS LAST, b.title, ba.role
+		LIMIT $` + strconv.Itoa(argIndex) + ` OFFSET $` + strconv.Itoa(argIndex+1)
+	args = append(args, pagination.Limit, pagination.Offset())
+
+	books := []response.AuthorBookResponse{}
+	err = authorHandler.DB.Select(&books, finalQuery, args...)
+	if err != nil {
+		log.Printf("GetAuthorBooks - Select error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to fetch books")
+		return
+	}
+
+	next, prev := pagination.Links(request, total)
+
+	helper.SuccessResponse(writer, http.StatusOK, response.PaginatedResponse{
+		Data:       books,
+		Page:       pagination.Page,
+		Limit:      pagination.Limit,
+		Total:      total,
+		TotalPages: pagination.TotalPages(total),
+		Next:       next,
+		Prev:       prev,
+	})
+}
+
+func (authorHandler *AuthorHandler) UpdateAuthor(writer http.ResponseWriter, request *http.Request) {
+	authorId, ok := parseAuthorId(writer, request, "UpdateAuthor")
+	if !ok {
+		return
+	}
+
+	var input authorInput
+
+	err := json.NewDecoder(request.Body).Decode(&input)
+	if err != nil {
+		log.Printf("UpdateAuthor - JSON decode error: %v", err)
+		helper.ErrorResponse(writer, http.StatusBadRequest, "Invalid JSON format")
+		return
+	}
+
+	input.Name = strings.TrimSpace(input.Name)
+	if input.Name == "" {
+		helper.ErrorResponse(writer, http.StatusBadRequest, "Author name is required")
+		return
+	}
+
+	tx, err := authorHandler.DB.Beginx()
+	if err != nil {
+		log.Printf("UpdateAuthor - Transaction start error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to start transaction")
+		return
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		UPDATE authors
+		SET name = $1,
+		    bio = $2
+		WHERE id = $3
+	`, input.Name, input.Bio, authorId)
+	if err != nil {
+		if helper.IsUniqueViolation(err) {
+			helper.ErrorResponse(writer, http.StatusConflict, "An author with this name already exists")
+			return
+		}
+		log.Printf("UpdateAuthor - Update error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to update author")
+		return
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		helper.ErrorResponse(writer, http.StatusNotFound, "Author not found")
+		return
+	}
+
+	// A rename changes the display author and search index of their books.
+	_, err = tx.Exec(`
+		UPDATE books b
+		SET author = `+bookAuthorDisplayColumn+`
+		WHERE b.id IN (SELECT book_id FROM book_authors WHERE author_id = $1)
+	`, authorId)
+	if err != nil {
+		log.Printf("UpdateAuthor - Update books error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to update author")
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		log.Printf("UpdateAuthor - Transaction commit error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to commit transaction")
+		return
+	}
+
+	refreshAutocomplete(authorHandler.Autocomplete, authorHandler.DB)
+
+	helper.SuccessResponse(writer, http.StatusOK, map[string]string{
+		"message": "Author updated successfully",
+	})
+}
+
+func (authorHandler *AuthorHandler) DeleteAuthor(writer http.ResponseWriter, request *http.Request) {
+	authorId, ok := parseAuthorId(writer, request, "DeleteAuthor")
+	if !ok {
+		return
+	}
+
+	var bookCount int
+	err := authorHandler.DB.Get(&bookCount, `SELECT COUNT(*) FROM book_authors WHERE author_id = $1`, authorId)
+	if err != nil {
+		log.Printf("DeleteAuthor - Count error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	if bookCount > 0 {
+		helper.ErrorResponse(writer, http.StatusConflict, "Author is still linked to books, remove them from those books first")
+		return
+	}
+
+	result, err := authorHandler.DB.Exec(`DELETE FROM authors WHERE id = $1`, authorId)
+	if err != nil {
+		log.Printf("DeleteAuthor - Delete error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to delete author")
+		return
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		helper.ErrorResponse(writer, http.StatusNotFound, "Author not found")
+		return
+	}
+
+	refreshAutocomplete(authorHandler.Autocomplete, authorHandler.DB)
+
+	helper.SuccessResponse(writer, http.StatusOK, map[string]string{
+		"message": "Author deleted successfully",
+	})
+}
+
+func parseAuthorId(writer http.ResponseWriter, request *http.Request, logPrefix string) (int, bool) {
+	id := mux.Vars(request)["id"]
+
+	authorId, err := strconv.Atoi(id)
+	if err != nil {
+		log.Printf("%s - Invalid ID: %s, error: %v", logPrefix, id, err)
+		helper.ErrorResponse(writer, http.StatusBadRequest, "Invalid author ID")
+		return 0, false
+	}
+
+	return authorId, true
+}
+
+// validateContributors checks the roles and that each contributor names an
+// author, and defaults an empty role to author.
+func validateContributors(contributors []models.BookContributor) string {
+	for i := range contributors {
+		contributor := &contributors[i]
+		contributor.Name = strings.TrimSpace(contributor.Name)
+
+		if contributor.AuthorID == nil && contributor.Name == "" {
+			return "Each contributor needs an author_id or a name"
+		}
+		if contributor.Role == "" {
+			contributor.Role = contributorRoleAuthor
+		}
+		if !validContributorRoles[contributor.Role] {
+			return "Contributor role must be one of author, editor, translator, illustrator"
+		}
+	}
+	return ""
+}
+
+// contributorsFromAuthor is the contributor list for a book that only has the
+// free-text author string.
+func contributorsFromAuthor(author string) []models.BookContributor {
+	author = strings.TrimSpace(author)
+	if author == "" {
+		return []models.BookContributor{}
+	}
+	return []models.BookContributor{{Name: author, Role: contributorRoleAuthor}}
+}
+
+// setBookContributors replaces the contributors of bookId, creating authors
+// given by name when they do not exist yet, and rewrites books.author from
+// the new list. Contributors must be validated first.
+func setBookContributors(tx *sqlx.Tx, bookId int, contributors []models.BookContributor) error {
+	_, err := tx.Exec(`DELETE FROM book_authors WHERE book_id = $1`, bookId)
+	if err != nil {
+		return err
+	}
+
+	for position, contributor := range contributors {
+		var authorId int
+		if contributor.AuthorID != nil {
+			err = tx.Get(&authorId, `SELECT id FROM authors WHERE id = $1`, *contributor.AuthorID)
+			if errors.Is(err, sql.ErrNoRows) {
+				return errAuthorNotFound
+			}
+		} else {
+			err = tx.Get(&authorId, `
+				INSERT INTO authors (name)
+				VALUES ($1)
+				ON CONFLICT ((lower(name))) DO UPDATE SET name = authors.name
+				RETURNING id
+			`, contributor.Name)
+		}
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`
+			INSERT INTO book_authors (book_id, author_id, role, position)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT DO NOTHING
+		`, bookId, authorId, contributor.Role, position+1)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec(`UPDATE books b SET author = `+bookAuthorDisplayColumn+` WHERE b.id = $1`, bookId)
+	return err
+}
+
+// updateBookContributors is used when a book is edited. An explicit list
+// replaces the contributors. Otherwise only a changed author string is
+// applied, replacing the authors and keeping editors and translators.
+func updateBookContributors(tx *sqlx.Tx, bookId int, previousAuthor string, book models.Book) error {
+	if book.Contributors != nil {
+		return setBookContributors(tx, bookId, book.Contributors)
+	}
+
+	if strings.TrimSpace(book.Author) == previousAuthor {
+		return nil
+	}
+
+	var others []models.BookContributor
+	err := tx.Select(&others, `
+		SELECT ba.author_id, a.name, ba.role
+		FROM book_authors ba
+		JOIN authors a ON ba.author_id = a.id
+		WHERE ba.book_id = $1 AND ba.role <> 'author'
+		ORDER BY ba.position
+	`, bookId)
+	if err != nil {
+		return err
+	}
+
+	return setBookContributors(tx, bookId, append(contributorsFromAuthor(book.Author), others...))
+}
+
+func bookContributors(queryer sqlx.Queryer, bookId int) ([]response.ContributorResponse, error) {
+	contributors := []response.ContributorResponse{}
+	err := sqlx.Select(queryer, &contributors, `
+		SELECT ba.author_id, a.name, ba.role
+		FROM book_authors ba
+		JOIN authors a ON ba.author_id = a.id
+		WHERE ba.book_id = $1
+		ORDER BY ba.role <> 'author', ba.position
+	`, bookId)
+	return contributors, err
+}
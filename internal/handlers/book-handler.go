@@ -6,18 +6,66 @@ import (
 	"errors"
 	"log"
 	"net/http"
+	"regexp"
 	"strconv"
 	"strings"
 
+	"github.com/faqq11/lib-management/internal/autocomplete"
 	"github.com/faqq11/lib-management/internal/helper"
 	"github.com/faqq11/lib-management/internal/models"
 	"github.com/faqq11/lib-management/internal/models/response"
 	"github.com/gorilla/mux"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
 type BookHandler struct {
-	DB *sqlx.DB
+	DB           *sqlx.DB
+	Autocomplete *autocomplete.Index
+}
+
+// availableStockColumn counts the copies of book b that are on the shelf.
+const availableStockColumn = `(SELECT COUNT(*) FROM items i WHERE i.book_id = b.id AND i.status = 'available')`
+
+const bookSelectColumns = `
+			b.id, 
+			b.title, 
+			b.author, 
+			b.isbn10,
+			b.isbn13,
+			b.description,
+			b.publication_year,
+			b.publisher,
+			b.edition,
+			b.language,
+			b.page_count,
+			b.work_id,
+			b.call_number,
+			b.call_number_scheme,
+			b.room,
+			b.shelf,
+			b.category_id,
+			c.name AS category,
+			` + availableStockColumn + ` AS stock,
+			b.created_at`
+
+const bookFromClause = `
+		FROM books b
+		LEFT JOIN categories c ON b.category_id = c.id
+`
+
+const bookSelectQuery = `
+		SELECT ` + bookSelectColumns + bookFromClause
+
+var languageCodePattern = regexp.MustCompile(`^[a-z]{2,3}$`)
+
+var bookSortColumns = map[string]string{
+	"title":      "b.title",
+	"author":     "b.author",
+	"created_at": "b.created_at",
+	"stock":      "stock",
+	// Byte order of the sort key is shelf order.
+	"call_number": `b.call_number_sort COLLATE "C"`,
 }
 
 func (bookHandler *BookHandler) InsertBook(writer http.ResponseWriter, request *http.Request) {
@@ -35,22 +83,113 @@ func (bookHandler *BookHandler) InsertBook(writer http.ResponseWriter, request *
 		return
 	}
 
-	var title string
-	err = bookHandler.DB.Get(&title, `SELECT title FROM books WHERE title = $1`, bookInput.Title)
+	if bookInput.Stock < 0 {
+		helper.ErrorResponse(writer, http.StatusBadRequest, "Stock cannot be negative")
+		return
+	}
+
+	if message := validateContributors(bookInput.Contributors); message != "" {
+		helper.ErrorResponse(writer, http.StatusBadRequest, message)
+		return
+	}
+
+	err = normalizeBookISBN(&bookInput)
+	if err != nil {
+		helper.ErrorResponse(writer, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	err = normalizeEditionFields(&bookInput)
+	if err != nil {
+		helper.ErrorResponse(writer, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	err = normalizeShelfLocation(&bookInput)
+	if err != nil {
+		helper.ErrorResponse(writer, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	tx, err := bookHandler.DB.Beginx()
+	if err != nil {
+		log.Printf("InsertBook - Transaction start error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to start transaction")
+		return
+	}
+	defer tx.Rollback()
+
+	bookId, err := findExistingBook(tx, bookInput)
 
 	if errors.Is(err, sql.ErrNoRows) {
-		_, err = bookHandler.DB.Exec(`
-				INSERT INTO books (title, author, category_id, stock)
-				VALUES ($1, $2, $3, $4)
-			`, bookInput.Title, bookInput.Author, bookInput.CategoryID, bookInput.Stock)
+		var workId int
+		workId, err = resolveBookWork(tx, bookInput)
 		if err != nil {
+			if errors.Is(err, errWorkNotFound) {
+				helper.ErrorResponse(writer, http.StatusBadRequest, err.Error())
+				return
+			}
+			log.Printf("InsertBook - Resolve work error: %v", err)
+			helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to save work")
+			return
+		}
+
+		err = tx.Get(&bookId, `
+				INSERT INTO books (title, author, isbn10, isbn13, description, publication_year, publisher, edition, language, page_count, work_id,
+					call_number, call_number_scheme, call_number_sort, room, shelf, category_id)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)
+				RETURNING id
+			`, bookInput.Title, bookInput.Author, bookInput.ISBN10, bookInput.ISBN13, bookInput.Description, bookInput.PublicationYear,
+			bookInput.Publisher, bookInput.Edition, bookInput.Language, bookInput.PageCount, workId,
+			bookInput.CallNumber, bookInput.CallNumberScheme, bookInput.CallNumberSort, bookInput.Room, bookInput.Shelf, bookInput.CategoryID)
+		if err != nil {
+			if helper.IsUniqueViolation(err) {
+				helper.ErrorResponse(writer, http.StatusConflict, "A book with this ISBN already exists")
+				return
+			}
 			log.Printf("InsertBook - Insert error: %v", err)
 			helper.ErrorResponse(writer, http.StatusInternalServerError, err.Error())
 			return
 		}
 
+		contributors := bookInput.Contributors
+		if contributors == nil {
+			contributors = contributorsFromAuthor(bookInput.Author)
+		}
+
+		err = setBookContributors(tx, bookId, contributors)
+		if err != nil {
+			if errors.Is(err, errAuthorNotFound) {
+				helper.ErrorResponse(writer, http.StatusBadRequest, err.Error())
+				return
+			}
+			log.Printf("InsertBook - Set contributors error: %v", err)
+			helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to save contributors")
+			return
+		}
+
+		_, err = tx.Exec(`
+				INSERT INTO items (book_id)
+				SELECT $1 FROM generate_series(1, $2)
+			`, bookId, bookInput.Stock)
+		if err != nil {
+			log.Printf("InsertBook - Insert copies error: %v", err)
+			helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to add copies")
+			return
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			log.Printf("InsertBook - Transaction commit error: %v", err)
+			helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to commit transaction")
+			return
+		}
+
+		refreshAutocomplete(bookHandler.Autocomplete, bookHandler.DB, bookId)
+
 		helper.SuccessResponse(writer, http.StatusCreated, map[string]interface{}{
 			"message": "Book created successfully",
+			"id":      bookId,
 		})
 		return
 
@@ -60,45 +199,124 @@ func (bookHandler *BookHandler) InsertBook(writer http.ResponseWriter, request *
 		return
 	}
 
-	_, err = bookHandler.DB.Exec(`
-			UPDATE books
-			SET stock = stock + 1
-			WHERE title = $1
-    `, bookInput.Title)
+	var itemId int
+	err = tx.Get(&itemId, `INSERT INTO items (book_id) VALUES ($1) RETURNING id`, bookId)
 	if err != nil {
-		log.Printf("InsertBook - Update stock error: %v", err)
+		log.Printf("InsertBook - Insert copy error: %v", err)
 		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to increase stock")
 		return
 	}
 
+	err = allocateCopy(tx, bookId, itemId)
+	if err != nil {
+		log.Printf("InsertBook - Allocate copy error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to increase stock")
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		log.Printf("InsertBook - Transaction commit error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to commit transaction")
+		return
+	}
+
 	helper.SuccessResponse(writer, http.StatusOK, map[string]interface{}{
-		"message": "Book already exists. Stock increased by 1",
+		"message": "Book already exists. Added one more copy",
+		"id":      bookId,
 	})
 }
 
 func (bookHandler *BookHandler) GetAllBooks(writer http.ResponseWriter, request *http.Request) {
-	var books []response.BookResponse
+	query := request.URL.Query()
 
-	err := bookHandler.DB.Select(&books, `
-		SELECT 
-			b.id, 
-			b.title, 
-			b.author, 
-			b.category_id,
-			c.name AS category,
-			b.stock, 
-			b.created_at
-		FROM books b
-		LEFT JOIN categories c ON b.category_id = c.id
-		ORDER BY b.id;
-    `)
+	pagination, err := helper.ParsePagination(request)
+	if err != nil {
+		helper.ErrorResponse(writer, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var args []interface{}
+	var conditions []string
+
+	argIndex := 1
+
+	if author := query.Get("author"); author != "" {
+		conditions = append(conditions, "b.author ILIKE $"+strconv.Itoa(argIndex))
+		args = append(args, "%"+author+"%")
+		argIndex++
+	}
+
+	if inStock := query.Get("in_stock"); inStock != "" {
+		inStockBool, err := strconv.ParseBool(inStock)
+		if err != nil {
+			helper.ErrorResponse(writer, http.StatusBadRequest, "in_stock must be true or false")
+			return
+		}
+		if inStockBool {
+			conditions = append(conditions, availableStockColumn+" > 0")
+		} else {
+			conditions = append(conditions, availableStockColumn+" = 0")
+		}
+	}
+
+	sortColumn := "b.id"
+	if sort := query.Get("sort"); sort != "" {
+		column, ok := bookSortColumns[sort]
+		if !ok {
+			helper.ErrorResponse(writer, http.StatusBadRequest, "sort must be one of title, author, created_at, stock, call_number")
+			return
+		}
+		sortColumn = column
+	}
+
+	sortOrder := "ASC"
+	switch query.Get("order") {
+	case "", "asc":
+	case "desc":
+		sortOrder = "DESC"
+	default:
+		helper.ErrorResponse(writer, http.StatusBadRequest, "order must be asc or desc")
+		return
+	}
+
+	whereClause := ""
+	if len(conditions) > 0 {
+		whereClause = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int
+	err = bookHandler.DB.Get(&total, "SELECT COUNT(*) FROM books b"+whereClause, args...)
+	if err != nil {
+		log.Printf("GetAllBooks - Count error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to count books")
+		return
+	}
+
+	finalQuery := bookSelectQuery + whereClause +
+		" ORDER BY " + sortColumn + " " + sortOrder + " NULLS LAST, b.id " + sortOrder +
+		" LIMIT $" + strconv.Itoa(argIndex) + " OFFSET $" + strconv.Itoa(argIndex+1)
+	args = append(args, pagination.Limit, pagination.Offset())
+
+	books := []response.BookResponse{}
+	err = bookHandler.DB.Select(&books, finalQuery, args...)
 	if err != nil {
 		log.Printf("GetAllBooks - Select error: %v", err)
 		helper.ErrorResponse(writer, http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	helper.SuccessResponse(writer, http.StatusOK, books)
+	next, prev := pagination.Links(request, total)
+
+	helper.SuccessResponse(writer, http.StatusOK, response.PaginatedResponse{
+		Data:       books,
+		Page:       pagination.Page,
+		Limit:      pagination.Limit,
+		Total:      total,
+		TotalPages: pagination.TotalPages(total),
+		Next:       next,
+		Prev:       prev,
+	})
 }
 
 func (bookHandler *BookHandler) GetBookById(writer http.ResponseWriter, request *http.Request) {
@@ -114,17 +332,7 @@ func (bookHandler *BookHandler) GetBookById(writer http.ResponseWriter, request
 
 	var book response.BookResponse
 
-	err = bookHandler.DB.Get(&book, `
-    SELECT 
-			b.id, 
-			b.title, 
-			b.author, 
-			b.category_id,
-			c.name AS category,
-			b.stock, 
-			b.created_at
-    FROM books b
-    JOIN categories c ON b.category_id = c.id
+	err = bookHandler.DB.Get(&book, bookSelectQuery+`
     WHERE b.id = $1
     `, bookId)
 	if err != nil {
@@ -138,156 +346,181 @@ func (bookHandler *BookHandler) GetBookById(writer http.ResponseWriter, request
 		return
 	}
 
-	helper.SuccessResponse(writer, http.StatusOK, book)
-}
-
-func (bookHandler *BookHandler) UpdateBook(writer http.ResponseWriter, request *http.Request) {
-	vars := mux.Vars(request)
-	id := vars["id"]
-
-	bookId, err := strconv.Atoi(id)
+	book.Contributors, err = bookContributors(bookHandler.DB, bookId)
 	if err != nil {
-		log.Printf("UpdateBook - Invalid ID: %s, error: %v", id, err)
-		helper.ErrorResponse(writer, http.StatusBadRequest, "Invalid book ID")
+		log.Printf("GetBookById - Contributors error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Internal server error")
 		return
 	}
 
-	var book models.Book
-
-	err = json.NewDecoder(request.Body).Decode(&book)
+	book.Tags, err = bookTags(bookHandler.DB, bookId)
 	if err != nil {
-		log.Printf("UpdateBook - JSON decode error: %v", err)
-		helper.ErrorResponse(writer, http.StatusBadRequest, "Invalid JSON format")
+		log.Printf("GetBookById - Tags error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Internal server error")
 		return
 	}
 
-	result, err := bookHandler.DB.Exec(`
-    UPDATE books
-    SET title = $1,
-        author = $2,
-        category_id = $3,
-        stock = $4
-    WHERE id = $5
-    `, book.Title, book.Author, book.CategoryID, book.Stock, bookId)
+	helper.SuccessResponse(writer, http.StatusOK, book)
+}
+
+func (bookHandler *BookHandler) GetBookByISBN(writer http.ResponseWriter, request *http.Request) {
+	vars := mux.Vars(request)
 
+	isbn13, err := helper.NormalizeISBN(vars["isbn"])
 	if err != nil {
-		log.Printf("UpdateBook - Update error: %v", err)
-		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to update book")
+		helper.ErrorResponse(writer, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	rowsAffected, err := result.RowsAffected()
+	var book response.BookResponse
+	err = bookHandler.DB.Get(&book, bookSelectQuery+` WHERE b.isbn13 = $1`, isbn13)
 	if err != nil {
-		log.Printf("UpdateBook - RowsAffected error: %v", err)
-		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to check update result")
-		return
-	}
+		if errors.Is(err, sql.ErrNoRows) {
+			helper.ErrorResponse(writer, http.StatusNotFound, "Book not found")
+			return
+		}
 
-	if rowsAffected == 0 {
-		helper.ErrorResponse(writer, http.StatusNotFound, "Book not found")
+		log.Printf("GetBookByISBN - Database error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Internal server error")
 		return
 	}
 
-	helper.SuccessResponse(writer, http.StatusOK, map[string]string{
-		"message": "Book updated successfully",
-	})
+	helper.SuccessResponse(writer, http.StatusOK, book)
 }
 
-func (bookHandler *BookHandler) IncreaseStock(writer http.ResponseWriter, request *http.Request) {
+func (bookHandler *BookHandler) UpdateBook(writer http.ResponseWriter, request *http.Request) {
 	vars := mux.Vars(request)
 	id := vars["id"]
 
 	bookId, err := strconv.Atoi(id)
 	if err != nil {
-		log.Printf("IncreaseStock - Invalid ID: %s, error: %v", id, err)
+		log.Printf("UpdateBook - Invalid ID: %s, error: %v", id, err)
 		helper.ErrorResponse(writer, http.StatusBadRequest, "Invalid book ID")
 		return
 	}
 
-	result, err := bookHandler.DB.Exec(`
-		UPDATE books
-		SET stock = stock + 1
-		WHERE id = $1
-	`, bookId)
+	var book models.Book
 
+	err = json.NewDecoder(request.Body).Decode(&book)
 	if err != nil {
-		log.Printf("IncreaseStock - Update error: %v", err)
-		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to update stock")
+		log.Printf("UpdateBook - JSON decode error: %v", err)
+		helper.ErrorResponse(writer, http.StatusBadRequest, "Invalid JSON format")
 		return
 	}
 
-	rowsAffected, err := result.RowsAffected()
+	err = normalizeBookISBN(&book)
 	if err != nil {
-		log.Printf("IncreaseStock - RowsAffected error: %v", err)
-		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to check update result")
+		helper.ErrorResponse(writer, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	if rowsAffected == 0 {
-		helper.ErrorResponse(writer, http.StatusNotFound, "Book not found")
+	err = normalizeEditionFields(&book)
+	if err != nil {
+		helper.ErrorResponse(writer, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	helper.SuccessResponse(writer, http.StatusOK, map[string]string{
-		"message": "Stock increased by 1",
-	})
-}
+	err = normalizeShelfLocation(&book)
+	if err != nil {
+		helper.ErrorResponse(writer, http.StatusBadRequest, err.Error())
+		return
+	}
 
-func (bookHandler *BookHandler) DecreaseStock(writer http.ResponseWriter, request *http.Request) {
-	vars := mux.Vars(request)
-	id := vars["id"]
+	if message := validateContributors(book.Contributors); message != "" {
+		helper.ErrorResponse(writer, http.StatusBadRequest, message)
+		return
+	}
 
-	bookId, err := strconv.Atoi(id)
+	tx, err := bookHandler.DB.Beginx()
 	if err != nil {
-		log.Printf("DecreaseStock - Invalid ID: %s, error: %v", id, err)
-		helper.ErrorResponse(writer, http.StatusBadRequest, "Invalid book ID")
+		log.Printf("UpdateBook - Transaction start error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to start transaction")
 		return
 	}
+	defer tx.Rollback()
 
-	var stock int
-
-	err = bookHandler.DB.Get(&stock, `SELECT stock FROM books WHERE id = $1`, bookId)
+	var previousAuthor string
+	err = tx.Get(&previousAuthor, `SELECT coalesce(author, '') FROM books WHERE id = $1 FOR UPDATE`, bookId)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			helper.ErrorResponse(writer, http.StatusNotFound, "Book not found")
 			return
 		}
-		log.Printf("DecreaseStock - Select stock error: %v", err)
-		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to fetch stock")
+		log.Printf("UpdateBook - Select error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to update book")
 		return
 	}
 
-	if stock <= 0 {
-		helper.ErrorResponse(writer, http.StatusBadRequest, "Stock is already 0, cannot decrease")
-		return
+	if book.WorkID != nil {
+		_, err = resolveBookWork(tx, book)
+		if err != nil {
+			if errors.Is(err, errWorkNotFound) {
+				helper.ErrorResponse(writer, http.StatusBadRequest, err.Error())
+				return
+			}
+			log.Printf("UpdateBook - Resolve work error: %v", err)
+			helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to update book")
+			return
+		}
 	}
 
-	result, err := bookHandler.DB.Exec(`
-		UPDATE books
-    SET stock = stock - 1
-    WHERE id = $1
-    `, bookId)
+	// Leaving out work_id keeps the book in its current work.
+	_, err = tx.Exec(`
+    UPDATE books
+    SET title = $1,
+        author = $2,
+        isbn10 = $3,
+        isbn13 = $4,
+        description = $5,
+        publication_year = $6,
+        publisher = $7,
+        edition = $8,
+        language = $9,
+        page_count = $10,
+        work_id = coalesce($11, work_id),
+        call_number = $12,
+        call_number_scheme = $13,
+        call_number_sort = $14,
+        room = $15,
+        shelf = $16,
+        category_id = $17
+    WHERE id = $18
+    `, book.Title, book.Author, book.ISBN10, book.ISBN13, book.Description, book.PublicationYear,
+		book.Publisher, book.Edition, book.Language, book.PageCount, book.WorkID,
+		book.CallNumber, book.CallNumberScheme, book.CallNumberSort, book.Room, book.Shelf, book.CategoryID, bookId)
 
 	if err != nil {
-		log.Printf("DecreaseStock - Update error: %v", err)
-		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to update stock")
+		if helper.IsUniqueViolation(err) {
+			helper.ErrorResponse(writer, http.StatusConflict, "A book with this ISBN already exists")
+			return
+		}
+		log.Printf("UpdateBook - Update error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to update book")
 		return
 	}
 
-	rowsAffected, err := result.RowsAffected()
+	err = updateBookContributors(tx, bookId, previousAuthor, book)
 	if err != nil {
-		log.Printf("DecreaseStock - RowsAffected error: %v", err)
-		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to check update result")
+		if errors.Is(err, errAuthorNotFound) {
+			helper.ErrorResponse(writer, http.StatusBadRequest, err.Error())
+			return
+		}
+		log.Printf("UpdateBook - Update contributors error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to update book")
 		return
 	}
 
-	if rowsAffected == 0 {
-		helper.ErrorResponse(writer, http.StatusNotFound, "Book not found")
+	err = tx.Commit()
+	if err != nil {
+		log.Printf("UpdateBook - Transaction commit error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to commit transaction")
 		return
 	}
 
+	refreshAutocomplete(bookHandler.Autocomplete, bookHandler.DB, bookId)
+
 	helper.SuccessResponse(writer, http.StatusOK, map[string]string{
-		"message": "Stock decreased by 1",
+		"message": "Book updated successfully",
 	})
 }
 
@@ -315,37 +548,275 @@ func (bookHandler *BookHandler) DeleteBook(writer http.ResponseWriter, request *
 		return
 	}
 
+	refreshAutocomplete(bookHandler.Autocomplete, bookHandler.DB, bookId)
+
 	helper.SuccessResponse(writer, http.StatusOK, map[string]interface{}{
 		"message": "Book deleted successfully",
 	})
 }
 
+func (bookHandler *BookHandler) AutocompleteBooks(writer http.ResponseWriter, request *http.Request) {
+	prefix := strings.TrimSpace(request.URL.Query().Get("q"))
+	if prefix == "" {
+		helper.ErrorResponse(writer, http.StatusBadRequest, "Query parameter q is required")
+		return
+	}
+
+	limit := 10
+	if limitParam := request.URL.Query().Get("limit"); limitParam != "" {
+		parsed, err := strconv.Atoi(limitParam)
+		if err != nil || parsed < 1 {
+			helper.ErrorResponse(writer, http.StatusBadRequest, "Limit must be a positive integer")
+			return
+		}
+		limit = min(parsed, 50)
+	}
+
+	helper.SuccessResponse(writer, http.StatusOK, bookHandler.Autocomplete.Complete(prefix, limit))
+}
+
+// GetShelfList lists the books in a room in shelf order: by shelf, then by
+// call number. from skips ahead to the first book filed at or after that call
+// number, which is where a shelf reading usually starts.
+func (bookHandler *BookHandler) GetShelfList(writer http.ResponseWriter, request *http.Request) {
+	query := request.URL.Query()
+
+	pagination, err := helper.ParsePagination(request)
+	if err != nil {
+		helper.ErrorResponse(writer, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	room := strings.TrimSpace(query.Get("room"))
+	if room == "" {
+		helper.ErrorResponse(writer, http.StatusBadRequest, "room is required")
+		return
+	}
+
+	conditions := []string{"lower(b.room) = lower($1)"}
+	args := []interface{}{room}
+	argIndex := 2
+
+	if shelf := strings.TrimSpace(query.Get("shelf")); shelf != "" {
+		conditions = append(conditions, "lower(b.shelf) = lower($"+strconv.Itoa(argIndex)+")")
+		args = append(args, shelf)
+		argIndex++
+	}
+
+	if from := query.Get("from"); from != "" {
+		_, sortKey, err := helper.CallNumberSortKey(from, query.Get("scheme"))
+		if err != nil {
+			helper.ErrorResponse(writer, http.StatusBadRequest, err.Error())
+			return
+		}
+		conditions = append(conditions, `b.call_number_sort COLLATE "C" >= $`+strconv.Itoa(argIndex))
+		args = append(args, sortKey)
+		argIndex++
+	}
+
+	whereClause := " WHERE " + strings.Join(conditions, " AND ")
+
+	var total int
+	err = bookHandler.DB.Get(&total, "SELECT COUNT(*) FROM books b"+whereClause, args...)
+	if err != nil {
+		log.Printf("GetShelfList - Count error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to count books")
+		return
+	}
+
+	finalQuery := bookSelectQuery + whereClause +
+		` ORDER BY lower(b.shelf) NULLS LAST, b.call_number_sort COLLATE "C" NULLS LAST, b.id` +
+		" LIMIT $" + strconv.Itoa(argIndex) + " OFFSET $" + strconv.Itoa(argIndex+1)
+	args = append(args, pagination.Limit, pagination.Offset())
+
+	books := []response.BookResponse{}
+	err = bookHandler.DB.Select(&books, finalQuery, args...)
+	if err != nil {
+		log.Printf("GetShelfList - Select error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to fetch shelf list")
+		return
+	}
+
+	next, prev := pagination.Links(request, total)
+
+	helper.SuccessResponse(writer, http.StatusOK, response.PaginatedResponse{
+		Data:       books,
+		Page:       pagination.Page,
+		Limit:      pagination.Limit,
+		Total:      total,
+		TotalPages: pagination.TotalPages(total),
+		Next:       next,
+		Prev:       prev,
+	})
+}
+
+// findExistingBook returns the id of the book with the given ISBN, or with
+// the same title, author, publisher and edition when there is no ISBN. It
+// returns sql.ErrNoRows when the book is not in the catalog yet.
+func findExistingBook(queryer sqlx.Queryer, book models.Book) (int, error) {
+	var bookId int
+	if book.ISBN13 != nil {
+		err := sqlx.Get(queryer, &bookId, `SELECT id FROM books WHERE isbn13 = $1`, *book.ISBN13)
+		return bookId, err
+	}
+
+	err := sqlx.Get(queryer, &bookId, `
+		SELECT id FROM books
+		WHERE lower(title) = lower($1)
+		  AND lower(coalesce(author, '')) = lower($2)
+		  AND lower(coalesce(publisher, '')) = lower(coalesce($3, ''))
+		  AND lower(coalesce(edition, '')) = lower(coalesce($4, ''))
+		ORDER BY id
+		LIMIT 1
+	`, book.Title, book.Author, book.Publisher, book.Edition)
+	return bookId, err
+}
+
+// normalizeBookISBN validates whichever of isbn10 and isbn13 were sent and
+// fills in both forms from them. A book without an ISBN keeps both nil.
+func normalizeBookISBN(book *models.Book) error {
+	isbn13 := ""
+	for _, raw := range []*string{book.ISBN13, book.ISBN10} {
+		if raw == nil || strings.TrimSpace(*raw) == "" {
+			continue
+		}
+
+		normalized, err := helper.NormalizeISBN(*raw)
+		if err != nil {
+			return err
+		}
+		if isbn13 != "" && normalized != isbn13 {
+			return errors.New("isbn10 and isbn13 do not refer to the same book")
+		}
+		isbn13 = normalized
+	}
+
+	book.ISBN10 = nil
+	book.ISBN13 = nil
+	if isbn13 == "" {
+		return nil
+	}
+
+	book.ISBN13 = &isbn13
+	if isbn10, ok := helper.ISBN13To10(isbn13); ok {
+		book.ISBN10 = &isbn10
+	}
+
+	return nil
+}
+
+// normalizeEditionFields trims the edition details, turning blanks into nil,
+// and checks the language code and page count.
+func normalizeEditionFields(book *models.Book) error {
+	trimOptionalFields(&book.Publisher, &book.Edition, &book.Language)
+
+	if book.Language != nil {
+		language := strings.ToLower(*book.Language)
+		if !languageCodePattern.MatchString(language) {
+			return errors.New("language must be an ISO 639 code such as en or eng")
+		}
+		book.Language = &language
+	}
+
+	if book.PageCount != nil && *book.PageCount <= 0 {
+		return errors.New("page_count must be a positive number")
+	}
+
+	return nil
+}
+
+// normalizeShelfLocation trims the room and shelf and validates the call
+// number, filling in its scheme and sort key. Without a call number the
+// scheme is dropped too.
+func normalizeShelfLocation(book *models.Book) error {
+	trimOptionalFields(&book.Room, &book.Shelf, &book.CallNumber, &book.CallNumberScheme)
+	book.CallNumberSort = nil
+
+	if book.CallNumber == nil {
+		book.CallNumberScheme = nil
+		return nil
+	}
+
+	scheme := ""
+	if book.CallNumberScheme != nil {
+		scheme = strings.ToLower(*book.CallNumberScheme)
+	}
+
+	scheme, sortKey, err := helper.CallNumberSortKey(*book.CallNumber, scheme)
+	if err != nil {
+		return err
+	}
+
+	callNumber := strings.Join(strings.Fields(*book.CallNumber), " ")
+	book.CallNumber = &callNumber
+
+	book.CallNumberScheme = &scheme
+	book.CallNumberSort = &sortKey
+	return nil
+}
+
+// trimOptionalFields trims each string in place, turning blanks into nil.
+func trimOptionalFields(fields ...**string) {
+	for _, field := range fields {
+		if *field == nil {
+			continue
+		}
+		value := strings.TrimSpace(**field)
+		if value == "" {
+			*field = nil
+			continue
+		}
+		*field = &value
+	}
+}
+
+// refreshAutocomplete updates the autocomplete index for the changed books
+// and the authors. A failed refresh only leaves completions stale, so it is
+// logged rather than failing the request.
+func refreshAutocomplete(index *autocomplete.Index, db *sqlx.DB, bookIds ...int) {
+	err := index.Update(db, bookIds...)
+	if err != nil {
+		log.Printf("refreshAutocomplete - Update error: %v", err)
+	}
+}
+
+// bookSimilarityColumn scores how closely $1 matches the title or author of
+// book b, from 0 to 1. It is only used for ranking, filtering goes through
+// bookSimilarityCondition so the trigram indexes are used.
+const bookSimilarityColumn = `GREATEST(word_similarity($1, b.title), word_similarity($1, coalesce(b.author, '')))`
+
+// bookSimilarityCondition matches books whose title or author is at least
+// pg_trgm.word_similarity_threshold similar to $1.
+const bookSimilarityCondition = `($1 <% b.title OR $1 <% b.author)`
+
 func (bookHandler *BookHandler) SearchBooks(writer http.ResponseWriter, request *http.Request) {
-	title := request.URL.Query().Get("title")
-	categoryID := request.URL.Query().Get("category_id")
+	query := request.URL.Query()
+	searchQuery := strings.TrimSpace(query.Get("q"))
+	title := query.Get("title")
+	categoryID := query.Get("category_id")
 
-	var books []response.BookResponse
-	var args []interface{}
-	var conditions []string
+	withFacets := false
+	if facets := query.Get("facets"); facets != "" {
+		var err error
+		withFacets, err = strconv.ParseBool(facets)
+		if err != nil {
+			helper.ErrorResponse(writer, http.StatusBadRequest, "facets must be true or false")
+			return
+		}
+	}
 
-	baseQuery := `
-		SELECT 
-			b.id, 
-			b.title, 
-			b.author, 
-			b.category_id,
-			c.name AS category,
-			b.stock, 
-			b.created_at
-		FROM books b
-		LEFT JOIN categories c ON b.category_id = c.id
-	`
+	var filters []string
+	var filterArgs []interface{}
 
+	// $1 is reserved for the search term when there is one.
 	argIndex := 1
+	if searchQuery != "" {
+		argIndex = 2
+	}
 
 	if title != "" {
-		conditions = append(conditions, "b.title ILIKE $"+strconv.Itoa(argIndex))
-		args = append(args, "%"+title+"%")
+		filters = append(filters, "b.title ILIKE $"+strconv.Itoa(argIndex))
+		filterArgs = append(filterArgs, "%"+title+"%")
 		argIndex++
 	}
 
@@ -356,21 +827,247 @@ func (bookHandler *BookHandler) SearchBooks(writer http.ResponseWriter, request
 			helper.ErrorResponse(writer, http.StatusBadRequest, "Invalid category ID")
 			return
 		}
-		conditions = append(conditions, "b.category_id = $"+strconv.Itoa(argIndex))
-		args = append(args, categoryIDInt)
+
+		includeSubcategories := false
+		if value := query.Get("include_subcategories"); value != "" {
+			includeSubcategories, err = strconv.ParseBool(value)
+			if err != nil {
+				helper.ErrorResponse(writer, http.StatusBadRequest, "include_subcategories must be true or false")
+				return
+			}
+		}
+
+		if includeSubcategories {
+			filters = append(filters, "b.category_id IN ("+categoryDescendantsQuery("$"+strconv.Itoa(argIndex))+")")
+		} else {
+			filters = append(filters, "b.category_id = $"+strconv.Itoa(argIndex))
+		}
+		filterArgs = append(filterArgs, categoryIDInt)
 		argIndex++
 	}
 
-	finalQuery := baseQuery
-	if len(conditions) > 0 {
-		finalQuery += " WHERE " + strings.Join(conditions, " AND ")
+	if author := query.Get("author"); author != "" {
+		filters = append(filters, `EXISTS (
+			SELECT 1 FROM book_authors ba
+			JOIN authors a ON ba.author_id = a.id
+			WHERE ba.book_id = b.id AND a.name ILIKE $`+strconv.Itoa(argIndex)+`)`)
+		filterArgs = append(filterArgs, "%"+author+"%")
+		argIndex++
 	}
-	finalQuery += " ORDER BY b.title"
 
-	err := bookHandler.DB.Select(&books, finalQuery, args...)
-	if err != nil {
-		log.Printf("SearchBooks - Select error: %v", err)
-		helper.ErrorResponse(writer, http.StatusInternalServerError, err.Error())
+	if authorID := query.Get("author_id"); authorID != "" {
+		authorIDInt, err := strconv.Atoi(authorID)
+		if err != nil {
+			helper.ErrorResponse(writer, http.StatusBadRequest, "Invalid author ID")
+			return
+		}
+		filters = append(filters, "EXISTS (SELECT 1 FROM book_authors ba WHERE ba.book_id = b.id AND ba.author_id = $"+strconv.Itoa(argIndex)+")")
+		filterArgs = append(filterArgs, authorIDInt)
+		argIndex++
+	}
+
+	if inStock := query.Get("in_stock"); inStock != "" {
+		inStockBool, err := strconv.ParseBool(inStock)
+		if err != nil {
+			helper.ErrorResponse(writer, http.StatusBadRequest, "in_stock must be true or false")
+			return
+		}
+		if inStockBool {
+			filters = append(filters, availableStockColumn+" > 0")
+		} else {
+			filters = append(filters, availableStockColumn+" = 0")
+		}
+	}
+
+	if decade := query.Get("decade"); decade != "" {
+		decadeInt, err := strconv.Atoi(decade)
+		if err != nil || decadeInt%10 != 0 {
+			helper.ErrorResponse(writer, http.StatusBadRequest, "decade must be a year ending in 0, e.g. 1990")
+			return
+		}
+		filters = append(filters, "b.publication_year BETWEEN $"+strconv.Itoa(argIndex)+" AND $"+strconv.Itoa(argIndex)+" + 9")
+		filterArgs = append(filterArgs, decadeInt)
+		argIndex++
+	}
+
+	// Every tag given must be on the book.
+	for _, tag := range query["tag"] {
+		if tag = strings.TrimSpace(tag); tag == "" {
+			continue
+		}
+		filters = append(filters, `EXISTS (
+			SELECT 1 FROM book_tags bt
+			JOIN tags t ON bt.tag_id = t.id
+			WHERE bt.book_id = b.id AND lower(t.name) = lower($`+strconv.Itoa(argIndex)+`))`)
+		filterArgs = append(filterArgs, tag)
+		argIndex++
+	}
+
+	if room := query.Get("room"); room != "" {
+		filters = append(filters, "lower(b.room) = lower($"+strconv.Itoa(argIndex)+")")
+		filterArgs = append(filterArgs, room)
+		argIndex++
+	}
+
+	if shelf := query.Get("shelf"); shelf != "" {
+		filters = append(filters, "lower(b.shelf) = lower($"+strconv.Itoa(argIndex)+")")
+		filterArgs = append(filterArgs, shelf)
+		argIndex++
+	}
+
+	if callNumber := query.Get("call_number"); callNumber != "" {
+		filters = append(filters, "b.call_number ILIKE $"+strconv.Itoa(argIndex))
+		filterArgs = append(filterArgs, callNumber+"%")
+		argIndex++
+	}
+
+	if publisher := query.Get("publisher"); publisher != "" {
+		filters = append(filters, "b.publisher ILIKE $"+strconv.Itoa(argIndex))
+		filterArgs = append(filterArgs, "%"+publisher+"%")
+		argIndex++
+	}
+
+	if edition := query.Get("edition"); edition != "" {
+		filters = append(filters, "b.edition ILIKE $"+strconv.Itoa(argIndex))
+		filterArgs = append(filterArgs, "%"+edition+"%")
+		argIndex++
+	}
+
+	if language := query.Get("language"); language != "" {
+		filters = append(filters, "b.language = lower($"+strconv.Itoa(argIndex)+")")
+		filterArgs = append(filterArgs, language)
+		argIndex++
+	}
+
+	if workID := query.Get("work_id"); workID != "" {
+		workIDInt, err := strconv.Atoi(workID)
+		if err != nil {
+			helper.ErrorResponse(writer, http.StatusBadRequest, "Invalid work ID")
+			return
+		}
+		filters = append(filters, "b.work_id = $"+strconv.Itoa(argIndex))
+		filterArgs = append(filterArgs, workIDInt)
+		argIndex++
+	}
+
+	rangeFilters := []struct {
+		param    string
+		column   string
+		operator string
+	}{
+		{"year_from", "b.publication_year", ">="},
+		{"year_to", "b.publication_year", "<="},
+		{"min_pages", "b.page_count", ">="},
+		{"max_pages", "b.page_count", "<="},
+	}
+	for _, rangeFilter := range rangeFilters {
+		value := query.Get(rangeFilter.param)
+		if value == "" {
+			continue
+		}
+		valueInt, err := strconv.Atoi(value)
+		if err != nil {
+			helper.ErrorResponse(writer, http.StatusBadRequest, rangeFilter.param+" must be a number")
+			return
+		}
+		filters = append(filters, rangeFilter.column+" "+rangeFilter.operator+" $"+strconv.Itoa(argIndex))
+		filterArgs = append(filterArgs, valueInt)
+		argIndex++
+	}
+
+	runSearch := func(queryer sqlx.Queryer, extraColumns string, condition string, orderBy string, searchArg interface{}) ([]response.BookSearchResult, error) {
+		conditions := filters
+		args := filterArgs
+		if condition != "" {
+			conditions = append([]string{condition}, filters...)
+			args = append([]interface{}{searchArg}, filterArgs...)
+		}
+
+		finalQuery := "SELECT " + bookSelectColumns + "," + extraColumns + bookFromClause
+		if len(conditions) > 0 {
+			finalQuery += " WHERE " + strings.Join(conditions, " AND ")
+		}
+		finalQuery += " ORDER BY " + orderBy
+
+		var books []response.BookSearchResult
+		err := sqlx.Select(queryer, &books, finalQuery, args...)
+		return books, err
+	}
+
+	var books []response.BookSearchResult
+	var err error
+
+	if searchQuery == "" {
+		books, err = runSearch(bookHandler.DB, `
+			NULL::real AS rank,
+			NULL AS snippet,
+			NULL AS match`, "", "b.title", nil)
+		if err != nil {
+			log.Printf("SearchBooks - Select error: %v", err)
+			helper.ErrorResponse(writer, http.StatusInternalServerError, err.Error())
+			return
+		}
+	} else {
+		tsQuery := helper.PrefixTSQuery(searchQuery)
+		if tsQuery != "" {
+			books, err = runSearch(bookHandler.DB, `
+			ts_rank(b.search_vector, to_tsquery('simple', $1)) AS rank,
+			ts_headline('simple', concat_ws(' - ', b.title, b.author, b.description), to_tsquery('simple', $1),
+				'StartSel=<mark>, StopSel=</mark>, MaxFragments=2, MaxWords=20, MinWords=5') AS snippet,
+			'fulltext' AS match`,
+				"b.search_vector @@ to_tsquery('simple', $1)", "rank DESC, b.title", tsQuery)
+			if err != nil {
+				log.Printf("SearchBooks - Full-text select error: %v", err)
+				helper.ErrorResponse(writer, http.StatusInternalServerError, err.Error())
+				return
+			}
+		}
+
+		// Nothing matched word for word, so fall back to trigram similarity to
+		// tolerate misspelled titles and author names.
+		if len(books) == 0 {
+			tx, err := beginSimilarityTx(bookHandler.DB, "pg_trgm.word_similarity_threshold")
+			if err != nil {
+				log.Printf("SearchBooks - Similarity threshold error: %v", err)
+				helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to search books")
+				return
+			}
+			defer tx.Rollback()
+
+			books, err = runSearch(tx, `
+			`+bookSimilarityColumn+`::real AS rank,
+			NULL AS snippet,
+			'fuzzy' AS match`,
+				bookSimilarityCondition, "rank DESC, b.title", searchQuery)
+			if err != nil {
+				log.Printf("SearchBooks - Fuzzy select error: %v", err)
+				helper.ErrorResponse(writer, http.StatusInternalServerError, err.Error())
+				return
+			}
+		}
+	}
+
+	if withFacets {
+		bookIds := make([]int64, len(books))
+		for i, book := range books {
+			bookIds[i] = int64(book.ID)
+		}
+
+		facets, err := bookSearchFacets(bookHandler.DB, bookIds)
+		if err != nil {
+			log.Printf("SearchBooks - Facets error: %v", err)
+			helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to count facets")
+			return
+		}
+
+		if books == nil {
+			books = []response.BookSearchResult{}
+		}
+
+		helper.SuccessResponse(writer, http.StatusOK, response.BookSearchResponse{
+			Data:   books,
+			Facets: facets,
+		})
 		return
 	}
 
@@ -383,3 +1080,174 @@ func (bookHandler *BookHandler) SearchBooks(writer http.ResponseWriter, request
 
 	helper.SuccessResponse(writer, http.StatusOK, books)
 }
+
+// bookSearchFacets counts the given books per category, author, availability,
+// publication decade, language and tag.
+func bookSearchFacets(db *sqlx.DB, bookIds []int64) (response.SearchFacets, error) {
+	facets := response.SearchFacets{
+		Categories:   []response.FacetCount{},
+		Authors:      []response.FacetCount{},
+		Availability: []response.FacetCount{},
+		Decades:      []response.FacetCount{},
+		Languages:    []response.FacetCount{},
+		Tags:         []response.FacetCount{},
+	}
+
+	if len(bookIds) == 0 {
+		return facets, nil
+	}
+
+	ids := pq.Array(bookIds)
+
+	err := db.Select(&facets.Categories, `
+		SELECT c.id::text AS value, coalesce(c.name, 'Uncategorized') AS label, COUNT(*) AS count
+		FROM books b
+		LEFT JOIN categories c ON b.category_id = c.id
+		WHERE b.id = ANY($1)
+		GROUP BY c.id, c.name
+		ORDER BY count DESC, label
+	`, ids)
+	if err != nil {
+		return facets, err
+	}
+
+	err = db.Select(&facets.Authors, `
+		SELECT a.name AS value, coalesce(a.name, 'Unknown') AS label, COUNT(DISTINCT b.id) AS count
+		FROM books b
+		LEFT JOIN book_authors ba ON ba.book_id = b.id AND ba.role = 'author'
+		LEFT JOIN authors a ON ba.author_id = a.id
+		WHERE b.id = ANY($1)
+		GROUP BY a.name
+		ORDER BY count DESC, label
+	`, ids)
+	if err != nil {
+		return facets, err
+	}
+
+	err = db.Select(&facets.Availability, `
+		SELECT value, value AS label, COUNT(*) AS count
+		FROM (
+			SELECT CASE WHEN `+availableStockColumn+` > 0 THEN 'in_stock' ELSE 'out_of_stock' END AS value
+			FROM books b
+			WHERE b.id = ANY($1)
+		) availability
+		GROUP BY value
+		ORDER BY value
+	`, ids)
+	if err != nil {
+		return facets, err
+	}
+
+	err = db.Select(&facets.Decades, `
+		SELECT decade::text AS value, coalesce(decade || 's', 'Unknown') AS label, COUNT(*) AS count
+		FROM (
+			SELECT (b.publication_year / 10) * 10 AS decade
+			FROM books b
+			WHERE b.id = ANY($1)
+		) decades
+		GROUP BY decade
+		ORDER BY decade DESC NULLS LAST
+	`, ids)
+	if err != nil {
+		return facets, err
+	}
+
+	err = db.Select(&facets.Languages, `
+		SELECT b.language AS value, coalesce(b.language, 'Unknown') AS label, COUNT(*) AS count
+		FROM books b
+		WHERE b.id = ANY($1)
+		GROUP BY b.language
+		ORDER BY count DESC, label
+	`, ids)
+	if err != nil {
+		return facets, err
+	}
+
+	err = db.Select(&facets.Tags, `
+		SELECT t.name AS value, t.name AS label, COUNT(*) AS count
+		FROM book_tags bt
+		JOIN tags t ON bt.tag_id = t.id
+		WHERE bt.book_id = ANY($1)
+		GROUP BY t.name
+		ORDER BY count DESC, label
+	`, ids)
+	if err != nil {
+		return facets, err
+	}
+
+	return facets, nil
+}
+
+func (bookHandler *BookHandler) GetSearchSuggestions(writer http.ResponseWriter, request *http.Request) {
+	searchQuery := strings.TrimSpace(request.URL.Query().Get("q"))
+	if searchQuery == "" {
+		helper.ErrorResponse(writer, http.StatusBadRequest, "Query parameter q is required")
+		return
+	}
+
+	limit := 5
+	if limitParam := request.URL.Query().Get("limit"); limitParam != "" {
+		parsed, err := strconv.Atoi(limitParam)
+		if err != nil || parsed < 1 {
+			helper.ErrorResponse(writer, http.StatusBadRequest, "Limit must be a positive integer")
+			return
+		}
+		limit = min(parsed, 20)
+	}
+
+	tx, err := beginSimilarityTx(bookHandler.DB, "pg_trgm.similarity_threshold")
+	if err != nil {
+		log.Printf("GetSearchSuggestions - Similarity threshold error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to fetch suggestions")
+		return
+	}
+	defer tx.Rollback()
+
+	var suggestions []response.SearchSuggestion
+	err = tx.Select(&suggestions, `
+		SELECT text, field, MAX(score) AS score
+		FROM (
+			SELECT title AS text, 'title' AS field, similarity($1, title) AS score
+			FROM books
+			WHERE title % $1
+			UNION ALL
+			SELECT name, 'author', similarity($1, name)
+			FROM authors
+			WHERE name % $1
+		) candidates
+		GROUP BY text, field
+		ORDER BY score DESC, text
+		LIMIT $2
+	`, searchQuery, limit)
+	if err != nil {
+		log.Printf("GetSearchSuggestions - Select error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to fetch suggestions")
+		return
+	}
+
+	if suggestions == nil {
+		suggestions = []response.SearchSuggestion{}
+	}
+
+	helper.SuccessResponse(writer, http.StatusOK, suggestions)
+}
+
+// beginSimilarityTx starts a read transaction with the given pg_trgm threshold
+// set to SEARCH_SIMILARITY_THRESHOLD. The % and <% operators compare against
+// that setting, and unlike the similarity functions they can use the trigram
+// indexes.
+func beginSimilarityTx(db *sqlx.DB, setting string) (*sqlx.Tx, error) {
+	tx, err := db.Beginx()
+	if err != nil {
+		return nil, err
+	}
+
+	threshold := helper.GetEnvFloat("SEARCH_SIMILARITY_THRESHOLD", 0.3)
+	_, err = tx.Exec(`SELECT set_config($1, $2, true)`, setting, strconv.FormatFloat(threshold, 'f', -1, 64))
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	return tx, nil
+}
@@ -2,10 +2,13 @@ package handlers
 
 import (
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"log"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/faqq11/lib-management/internal/helper"
@@ -19,6 +22,77 @@ type BorrowHandler struct {
 	DB *sqlx.DB
 }
 
+const borrowingStatusColumn = `
+		CASE 
+			WHEN br.returned_at IS NOT NULL THEN 'returned'
+			WHEN br.lost_at IS NOT NULL THEN 'lost'
+			WHEN br.due_at < now() THEN 'overdue'
+			ELSE 'borrowed'
+		END`
+
+const borrowingJoins = `
+		FROM borrowings br
+		JOIN books b ON br.book_id = b.id
+		LEFT JOIN items i ON br.item_id = i.id`
+
+// borrowingListColumns and borrowingListFromClause select rows for
+// response.BorrowingResponse.
+const borrowingListColumns = `
+			br.id,
+			br.user_id,
+			u.username,
+			br.book_id,
+			b.title AS book_title,
+			b.author,
+			br.borrowed_at,
+			br.due_at,
+			br.renewal_count,
+			br.returned_at,
+			i.barcode,
+			br.checked_out_by,
+			br.checked_in_by,
+			` + borrowingStatusColumn + ` AS status`
+
+const borrowingListFromClause = borrowingJoins + `
+		JOIN users u ON br.user_id = u.id`
+
+var borrowingSortColumns = map[string]string{
+	"borrowed_at": "br.borrowed_at",
+	"due_at":      "br.due_at",
+	"returned_at": "br.returned_at",
+	"username":    "u.username",
+	"title":       "b.title",
+}
+
+// circulationError is a refusal that is reported to the client as is, as
+// opposed to a database failure.
+type circulationError struct {
+	status  int
+	message string
+	reason  interface{}
+}
+
+func (e *circulationError) Error() string {
+	return e.message
+}
+
+// writeCirculationError answers a failed check out or check in: refusals keep
+// their status and message, anything else is logged and reported as a 500.
+func writeCirculationError(writer http.ResponseWriter, logPrefix string, err error) {
+	var refusal *circulationError
+	if errors.As(err, &refusal) {
+		if refusal.reason != nil {
+			helper.ErrorResponseWithReason(writer, refusal.status, refusal.message, refusal.reason)
+			return
+		}
+		helper.ErrorResponse(writer, refusal.status, refusal.message)
+		return
+	}
+
+	log.Printf("%s - Circulation error: %v", logPrefix, err)
+	helper.ErrorResponse(writer, http.StatusInternalServerError, "Internal server error")
+}
+
 func (borrowHandler *BorrowHandler) BorrowBook(writer http.ResponseWriter, request *http.Request) {
 	user := request.Context().Value(middleware.UserContextKey)
 	if user == nil {
@@ -39,6 +113,19 @@ func (borrowHandler *BorrowHandler) BorrowBook(writer http.ResponseWriter, reque
 		return
 	}
 
+	var borrowInput struct {
+		Barcode string `json:"barcode"`
+	}
+
+	if request.ContentLength != 0 {
+		err = json.NewDecoder(request.Body).Decode(&borrowInput)
+		if err != nil {
+			log.Printf("BorrowBook - JSON decode error: %v", err)
+			helper.ErrorResponse(writer, http.StatusBadRequest, "Invalid JSON format")
+			return
+		}
+	}
+
 	tx, err := borrowHandler.DB.Beginx()
 	if err != nil {
 		log.Printf("BorrowBook - Transaction start error: %v", err)
@@ -46,87 +133,398 @@ func (borrowHandler *BorrowHandler) BorrowBook(writer http.ResponseWriter, reque
 		return
 	}
 
-	defer func() {
+	defer tx.Rollback()
+
+	borrowId, dueAt, err := checkOutBook(tx, userId, userClaims.Role, bookId, borrowInput.Barcode, nil)
+	if err != nil {
+		writeCirculationError(writer, "BorrowBook", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		log.Printf("BorrowBook - Transaction commit error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to commit transaction")
+		return
+	}
+
+	helper.SuccessResponse(writer, http.StatusCreated, map[string]interface{}{
+		"message": "Book borrowed successfully",
+		"id":      borrowId,
+		"due_at":  dueAt,
+	})
+}
+
+func (borrowHandler *BorrowHandler) ReturnBook(writer http.ResponseWriter, request *http.Request) {
+	user := request.Context().Value(middleware.UserContextKey)
+	if user == nil {
+		helper.ErrorResponse(writer, http.StatusUnauthorized, "User context not found")
+		return
+	}
+
+	userClaims := user.(middleware.UserClaims)
+	userId := userClaims.UserID
+
+	vars := mux.Vars(request)
+	id := vars["id"]
+
+	borrowId, err := strconv.Atoi(id)
+	if err != nil {
+		log.Printf("ReturnBook - Invalid borrow ID: %s, error: %v", id, err)
+		helper.ErrorResponse(writer, http.StatusBadRequest, "Invalid borrow ID")
+		return
+	}
+
+	var returnInput struct {
+		Condition string `json:"condition"`
+	}
+
+	if request.ContentLength != 0 {
+		err = json.NewDecoder(request.Body).Decode(&returnInput)
 		if err != nil {
-			tx.Rollback()
+			log.Printf("ReturnBook - JSON decode error: %v", err)
+			helper.ErrorResponse(writer, http.StatusBadRequest, "Invalid JSON format")
+			return
 		}
-	}()
+	}
 
-	var existingBorrow int
-	err = tx.Get(&existingBorrow, `
-			SELECT COUNT(*) FROM borrowings 
-			WHERE user_id = $1 AND book_id = $2 AND returned_at IS NULL
-    `, userId, bookId)
+	if returnInput.Condition != "" && !validItemConditions[returnInput.Condition] {
+		helper.ErrorResponse(writer, http.StatusBadRequest, "Invalid condition")
+		return
+	}
+
+	tx, err := borrowHandler.DB.Beginx()
 	if err != nil {
-		log.Printf("BorrowBook - Check existing borrow error: %v", err)
-		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to check existing borrow")
+		log.Printf("ReturnBook - Transaction start error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to start transaction")
 		return
 	}
 
-	if existingBorrow > 0 {
-		helper.ErrorResponse(writer, http.StatusBadRequest, "You have already borrowed this book")
+	defer tx.Rollback()
+
+	err = checkInBorrowing(tx, borrowId, &userId, returnInput.Condition, nil)
+	if err != nil {
+		writeCirculationError(writer, "ReturnBook", err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		log.Printf("ReturnBook - Transaction commit error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to commit transaction")
+		return
+	}
+
+	helper.SuccessResponse(writer, http.StatusOK, map[string]interface{}{
+		"message": "Book returned successfully",
+	})
+}
+
+func (borrowHandler *BorrowHandler) DeskCheckOut(writer http.ResponseWriter, request *http.Request) {
+	user := request.Context().Value(middleware.UserContextKey)
+	if user == nil {
+		helper.ErrorResponse(writer, http.StatusUnauthorized, "User context not found")
+		return
+	}
+
+	staff := user.(middleware.UserClaims)
+
+	var checkOutInput struct {
+		UserID   int    `json:"user_id"`
+		Username string `json:"username"`
+		BookID   int    `json:"book_id"`
+		Barcode  string `json:"barcode"`
+	}
+
+	err := json.NewDecoder(request.Body).Decode(&checkOutInput)
+	if err != nil {
+		log.Printf("DeskCheckOut - JSON decode error: %v", err)
+		helper.ErrorResponse(writer, http.StatusBadRequest, "Invalid JSON format")
+		return
+	}
+
+	if checkOutInput.UserID == 0 && checkOutInput.Username == "" {
+		helper.ErrorResponse(writer, http.StatusBadRequest, "user_id or username is required")
 		return
 	}
 
-	var stock int
-	err = tx.Get(&stock, `SELECT stock FROM books WHERE id = $1`, bookId)
+	if checkOutInput.BookID == 0 && checkOutInput.Barcode == "" {
+		helper.ErrorResponse(writer, http.StatusBadRequest, "book_id or barcode is required")
+		return
+	}
+
+	tx, err := borrowHandler.DB.Beginx()
+	if err != nil {
+		log.Printf("DeskCheckOut - Transaction start error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to start transaction")
+		return
+	}
+	defer tx.Rollback()
+
+	var patron struct {
+		ID   int    `db:"id"`
+		Role string `db:"role"`
+	}
+
+	err = tx.Get(&patron, `
+		SELECT id, role FROM users
+		WHERE ($1 <> 0 AND id = $1) OR ($1 = 0 AND username = $2)
+	`, checkOutInput.UserID, checkOutInput.Username)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			helper.ErrorResponse(writer, http.StatusNotFound, "Book not found")
+			helper.ErrorResponse(writer, http.StatusNotFound, "Patron not found")
 			return
 		}
-		log.Printf("BorrowBook - Fetch stock error: %v", err)
-		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to fetch stock")
+		log.Printf("DeskCheckOut - Fetch patron error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to fetch patron")
 		return
 	}
 
-	if stock <= 0 {
-		helper.ErrorResponse(writer, http.StatusBadRequest, "Book is not available")
+	bookId := checkOutInput.BookID
+	if bookId == 0 {
+		err = tx.Get(&bookId, `SELECT book_id FROM items WHERE barcode = $1`, checkOutInput.Barcode)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				helper.ErrorResponse(writer, http.StatusNotFound, "Copy not found")
+				return
+			}
+			log.Printf("DeskCheckOut - Fetch copy error: %v", err)
+			helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to fetch copy")
+			return
+		}
+	}
+
+	borrowId, dueAt, err := checkOutBook(tx, patron.ID, patron.Role, bookId, checkOutInput.Barcode, &staff.UserID)
+	if err != nil {
+		writeCirculationError(writer, "DeskCheckOut", err)
 		return
 	}
 
-	_, err = tx.Exec(`
-			INSERT INTO borrowings (user_id, book_id, borrowed_at) 
-			VALUES ($1, $2, $3)
-    `, userId, bookId, time.Now())
+	err = tx.Commit()
 	if err != nil {
-		log.Printf("BorrowBook - Insert borrowing error: %v", err)
-		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to create borrowing record")
+		log.Printf("DeskCheckOut - Transaction commit error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to commit transaction")
 		return
 	}
 
-	result, err := tx.Exec(`UPDATE books SET stock = stock - 1 WHERE id = $1`, bookId)
+	helper.SuccessResponse(writer, http.StatusCreated, map[string]interface{}{
+		"message": "Book checked out successfully",
+		"id":      borrowId,
+		"user_id": patron.ID,
+		"due_at":  dueAt,
+	})
+}
+
+func (borrowHandler *BorrowHandler) DeskCheckIn(writer http.ResponseWriter, request *http.Request) {
+	user := request.Context().Value(middleware.UserContextKey)
+	if user == nil {
+		helper.ErrorResponse(writer, http.StatusUnauthorized, "User context not found")
+		return
+	}
+
+	staff := user.(middleware.UserClaims)
+
+	var checkInInput struct {
+		BorrowingID int    `json:"borrowing_id"`
+		Barcode     string `json:"barcode"`
+		Condition   string `json:"condition"`
+	}
+
+	err := json.NewDecoder(request.Body).Decode(&checkInInput)
 	if err != nil {
-		log.Printf("BorrowBook - Update stock error: %v", err)
-		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to update book stock")
+		log.Printf("DeskCheckIn - JSON decode error: %v", err)
+		helper.ErrorResponse(writer, http.StatusBadRequest, "Invalid JSON format")
+		return
+	}
+
+	if checkInInput.BorrowingID == 0 && checkInInput.Barcode == "" {
+		helper.ErrorResponse(writer, http.StatusBadRequest, "borrowing_id or barcode is required")
 		return
 	}
 
-	rowsAffected, err := result.RowsAffected()
+	if checkInInput.Condition != "" && !validItemConditions[checkInInput.Condition] {
+		helper.ErrorResponse(writer, http.StatusBadRequest, "Invalid condition")
+		return
+	}
+
+	tx, err := borrowHandler.DB.Beginx()
 	if err != nil {
-		log.Printf("BorrowBook - RowsAffected error: %v", err)
-		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to check update result")
+		log.Printf("DeskCheckIn - Transaction start error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to start transaction")
 		return
 	}
+	defer tx.Rollback()
+
+	borrowId := checkInInput.BorrowingID
+	if borrowId == 0 {
+		err = tx.Get(&borrowId, `
+			SELECT br.id FROM borrowings br
+			JOIN items i ON br.item_id = i.id
+			WHERE i.barcode = $1 AND br.returned_at IS NULL AND br.lost_at IS NULL
+		`, checkInInput.Barcode)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				helper.ErrorResponse(writer, http.StatusNotFound, "No active borrowing for this copy")
+				return
+			}
+			log.Printf("DeskCheckIn - Fetch borrowing error: %v", err)
+			helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to fetch borrowing data")
+			return
+		}
+	}
 
-	if rowsAffected == 0 {
-		helper.ErrorResponse(writer, http.StatusNotFound, "Book not found")
+	err = checkInBorrowing(tx, borrowId, nil, checkInInput.Condition, &staff.UserID)
+	if err != nil {
+		writeCirculationError(writer, "DeskCheckIn", err)
 		return
 	}
 
 	err = tx.Commit()
 	if err != nil {
-		log.Printf("BorrowBook - Transaction commit error: %v", err)
+		log.Printf("DeskCheckIn - Transaction commit error: %v", err)
 		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to commit transaction")
 		return
 	}
 
-	helper.SuccessResponse(writer, http.StatusCreated, map[string]interface{}{
-		"message": "Book borrowed successfully",
+	helper.SuccessResponse(writer, http.StatusOK, map[string]interface{}{
+		"message": "Book checked in successfully",
+		"id":      borrowId,
 	})
 }
 
-func (borrowHandler *BorrowHandler) ReturnBook(writer http.ResponseWriter, request *http.Request) {
+// checkOutBook lends a copy of bookId to userId inside tx. staffId is set when
+// a librarian checks the book out on the patron's behalf.
+func checkOutBook(tx *sqlx.Tx, userId int, role string, bookId int, barcode string, staffId *int) (int, time.Time, error) {
+	var existingBorrow int
+	err := tx.Get(&existingBorrow, `
+			SELECT COUNT(*) FROM borrowings 
+			WHERE user_id = $1 AND book_id = $2 AND returned_at IS NULL AND lost_at IS NULL
+    `, userId, bookId)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+
+	if existingBorrow > 0 {
+		return 0, time.Time{}, &circulationError{status: http.StatusBadRequest, message: "You have already borrowed this book"}
+	}
+
+	err = expireHolds(tx, bookId)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+
+	var bookExists bool
+	err = tx.Get(&bookExists, `SELECT EXISTS (SELECT 1 FROM books WHERE id = $1)`, bookId)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+
+	if !bookExists {
+		return 0, time.Time{}, &circulationError{status: http.StatusNotFound, message: "Book not found"}
+	}
+
+	refusal, err := evaluateCirculationPolicies(tx, userId, role, bookId)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+
+	if refusal != nil {
+		return 0, time.Time{}, &circulationError{status: http.StatusForbidden, message: refusal.Message, reason: refusal}
+	}
+
+	itemId, err := pickLoanItem(tx, userId, bookId, barcode)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+
+	loanDays, err := loanPeriodDays(tx, bookId, role)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+
+	borrowedAt := time.Now()
+	dueAt := borrowedAt.AddDate(0, 0, loanDays)
+
+	var borrowId int
+	err = tx.Get(&borrowId, `
+			INSERT INTO borrowings (user_id, book_id, item_id, borrowed_at, due_at, checked_out_by) 
+			VALUES ($1, $2, $3, $4, $5, $6)
+			RETURNING id
+    `, userId, bookId, itemId, borrowedAt, dueAt, staffId)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+
+	_, err = tx.Exec(`UPDATE items SET status = 'on_loan' WHERE id = $1`, itemId)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+
+	_, err = tx.Exec(`
+			UPDATE holds SET status = 'fulfilled'
+			WHERE user_id = $1 AND book_id = $2 AND status IN ('waiting', 'ready')
+	`, userId, bookId)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+
+	return borrowId, dueAt, nil
+}
+
+// checkInBorrowing closes borrowId inside tx. When ownerId is set only that
+// user may return it; staffId is set when a librarian checks the book in.
+func checkInBorrowing(tx *sqlx.Tx, borrowId int, ownerId *int, condition string, staffId *int) error {
+	var borrowData struct {
+		ID     int  `db:"id"`
+		BookID int  `db:"book_id"`
+		UserID int  `db:"user_id"`
+		ItemID *int `db:"item_id"`
+	}
+
+	err := tx.Get(&borrowData, `
+		SELECT id, book_id, user_id, item_id 
+		FROM borrowings 
+		WHERE id = $1 AND returned_at IS NULL AND lost_at IS NULL
+		FOR UPDATE
+	`, borrowId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return &circulationError{status: http.StatusNotFound, message: "Borrowing record not found or already returned"}
+		}
+		return err
+	}
+
+	if ownerId != nil && borrowData.UserID != *ownerId {
+		return &circulationError{status: http.StatusForbidden, message: "You can only return your own borrowed books"}
+	}
+
+	_, err = tx.Exec(`
+		UPDATE borrowings 
+		SET returned_at = $1,
+		    checked_in_by = $2
+		WHERE id = $3
+	`, time.Now(), staffId, borrowId)
+	if err != nil {
+		return err
+	}
+
+	// The overdue fine is final once the book is back.
+	err = accrueOverdueFines(tx, borrowData.UserID)
+	if err != nil {
+		return err
+	}
+
+	if borrowData.ItemID != nil {
+		err = checkInItem(tx, borrowData.BookID, *borrowData.ItemID, condition)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (borrowHandler *BorrowHandler) RenewBorrowing(writer http.ResponseWriter, request *http.Request) {
 	user := request.Context().Value(middleware.UserContextKey)
 	if user == nil {
 		helper.ErrorResponse(writer, http.StatusUnauthorized, "User context not found")
@@ -141,89 +539,120 @@ func (borrowHandler *BorrowHandler) ReturnBook(writer http.ResponseWriter, reque
 
 	borrowId, err := strconv.Atoi(id)
 	if err != nil {
-		log.Printf("ReturnBook - Invalid borrow ID: %s, error: %v", id, err)
+		log.Printf("RenewBorrowing - Invalid borrow ID: %s, error: %v", id, err)
 		helper.ErrorResponse(writer, http.StatusBadRequest, "Invalid borrow ID")
 		return
 	}
 
 	tx, err := borrowHandler.DB.Beginx()
 	if err != nil {
-		log.Printf("ReturnBook - Transaction start error: %v", err)
+		log.Printf("RenewBorrowing - Transaction start error: %v", err)
 		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to start transaction")
 		return
 	}
-
-	defer func() {
-		if err != nil {
-			tx.Rollback()
-		}
-	}()
+	defer tx.Rollback()
 
 	var borrowData struct {
-		ID     int `db:"id"`
-		BookID int `db:"book_id"`
-		UserID int `db:"user_id"`
+		BookID       int       `db:"book_id"`
+		UserID       int       `db:"user_id"`
+		DueAt        time.Time `db:"due_at"`
+		RenewalCount int       `db:"renewal_count"`
 	}
 
 	err = tx.Get(&borrowData, `
-		SELECT id, book_id, user_id 
-		FROM borrowings 
-		WHERE id = $1 AND returned_at IS NULL
+		SELECT book_id, user_id, due_at, renewal_count
+		FROM borrowings
+		WHERE id = $1 AND returned_at IS NULL AND lost_at IS NULL
+		FOR UPDATE
 	`, borrowId)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			helper.ErrorResponse(writer, http.StatusNotFound, "Borrowing record not found or already returned")
 			return
 		}
-		log.Printf("ReturnBook - Fetch borrowing data error: %v", err)
+		log.Printf("RenewBorrowing - Fetch borrowing data error: %v", err)
 		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to fetch borrowing data")
 		return
 	}
 
 	if borrowData.UserID != userId {
-		helper.ErrorResponse(writer, http.StatusForbidden, "You can only return your own borrowed books")
+		helper.ErrorResponse(writer, http.StatusForbidden, "You can only renew your own borrowed books")
 		return
 	}
 
-	result, err := tx.Exec(`
-		UPDATE borrowings 
-		SET returned_at = $1 
-		WHERE id = $2
-	`, time.Now(), borrowId)
+	maxRenewals := helper.GetEnvInt("MAX_RENEWALS", 2)
+	if borrowData.RenewalCount >= maxRenewals {
+		helper.ErrorResponse(writer, http.StatusBadRequest, "Maximum number of renewals reached")
+		return
+	}
+
+	var waitingHolds int
+	err = tx.Get(&waitingHolds, `
+		SELECT COUNT(*) FROM holds
+		WHERE book_id = $1 AND user_id <> $2 AND status = 'waiting'
+	`, borrowData.BookID, userId)
 	if err != nil {
-		log.Printf("ReturnBook - Update borrowing error: %v", err)
-		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to update borrowing record")
+		log.Printf("RenewBorrowing - Check holds error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to check holds")
 		return
 	}
 
-	rowsAffected, err := result.RowsAffected()
+	if waitingHolds > 0 {
+		helper.ErrorResponse(writer, http.StatusConflict, "Another patron is waiting for this book")
+		return
+	}
+
+	loanDays, err := loanPeriodDays(tx, borrowData.BookID, userClaims.Role)
+	if err != nil {
+		log.Printf("RenewBorrowing - Fetch loan period error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to determine loan period")
+		return
+	}
+
+	renewFrom := borrowData.DueAt
+	if now := time.Now(); now.After(renewFrom) {
+		renewFrom = now
+	}
+	dueAt := renewFrom.AddDate(0, 0, loanDays)
+
+	// Charge the days already overdue before the due date moves.
+	err = accrueOverdueFines(tx, borrowData.UserID)
 	if err != nil {
-		log.Printf("ReturnBook - RowsAffected error: %v", err)
-		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to check update result")
+		log.Printf("RenewBorrowing - Accrue fines error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to update fines")
 		return
 	}
 
-	if rowsAffected == 0 {
-		helper.ErrorResponse(writer, http.StatusNotFound, "Borrowing record not found")
+	err = settleOverdueFine(tx, borrowId)
+	if err != nil {
+		log.Printf("RenewBorrowing - Settle fine error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to update fines")
 		return
 	}
 
-	_, err = tx.Exec(`UPDATE books SET stock = stock + 1 WHERE id = $1`, borrowData.BookID)
+	_, err = tx.Exec(`
+		UPDATE borrowings
+		SET due_at = $1,
+		    renewal_count = renewal_count + 1
+		WHERE id = $2
+	`, dueAt, borrowId)
 	if err != nil {
-		log.Printf("ReturnBook - Update stock error: %v", err)
-		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to update book stock")
+		log.Printf("RenewBorrowing - Update borrowing error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to renew borrowing")
 		return
 	}
 
 	err = tx.Commit()
 	if err != nil {
-		log.Printf("ReturnBook - Transaction commit error: %v", err)
+		log.Printf("RenewBorrowing - Transaction commit error: %v", err)
 		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to commit transaction")
 		return
 	}
 
 	helper.SuccessResponse(writer, http.StatusOK, map[string]interface{}{
-		"message": "Book returned successfully",
+		"message":       "Borrowing renewed successfully",
+		"due_at":        dueAt,
+		"renewals_left": maxRenewals - borrowData.RenewalCount - 1,
 	})
 }
 
@@ -245,13 +674,12 @@ func (borrowHandler *BorrowHandler) GetUserBorrowings(writer http.ResponseWriter
 			b.title as book_title,
 			b.author,
 			br.borrowed_at,
+			br.due_at,
+			br.renewal_count,
 			br.returned_at,
-		CASE 
-			WHEN br.returned_at IS NULL THEN 'borrowed'
-			ELSE 'returned'
-		END as status
-		FROM borrowings br
-		JOIN books b ON br.book_id = b.id
+			i.barcode,
+			`+borrowingStatusColumn+` as status
+		`+borrowingJoins+`
 		WHERE br.user_id = $1
 		ORDER BY br.borrowed_at DESC
 	`, userId)
@@ -264,3 +692,202 @@ func (borrowHandler *BorrowHandler) GetUserBorrowings(writer http.ResponseWriter
 
 	helper.SuccessResponse(writer, http.StatusOK, borrowings)
 }
+
+func (borrowHandler *BorrowHandler) GetAllBorrowings(writer http.ResponseWriter, request *http.Request) {
+	query := request.URL.Query()
+
+	pagination, err := helper.ParsePagination(request)
+	if err != nil {
+		helper.ErrorResponse(writer, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	conditions, args, err := parseBorrowingFilters(query)
+	if err != nil {
+		helper.ErrorResponse(writer, http.StatusBadRequest, err.Error())
+		return
+	}
+	argIndex := len(args) + 1
+
+	sortColumn := "br.borrowed_at"
+	if sort := query.Get("sort"); sort != "" {
+		column, ok := borrowingSortColumns[sort]
+		if !ok {
+			helper.ErrorResponse(writer, http.StatusBadRequest, "sort must be one of borrowed_at, due_at, returned_at, username, title")
+			return
+		}
+		sortColumn = column
+	}
+
+	sortOrder := "DESC"
+	switch query.Get("order") {
+	case "", "desc":
+	case "asc":
+		sortOrder = "ASC"
+	default:
+		helper.ErrorResponse(writer, http.StatusBadRequest, "order must be asc or desc")
+		return
+	}
+
+	fromClause := borrowingListFromClause
+	if len(conditions) > 0 {
+		fromClause += " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int
+	err = borrowHandler.DB.Get(&total, "SELECT COUNT(*) "+fromClause, args...)
+	if err != nil {
+		log.Printf("GetAllBorrowings - Count error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to count borrowings")
+		return
+	}
+
+	finalQuery := `
+		SELECT ` + borrowingListColumns + `
+		` + fromClause + `
+		ORDER BY ` + sortColumn + ` ` + sortOrder + ` NULLS LAST, br.id ` + sortOrder + `
+		LIMIT $` + strconv.Itoa(argIndex) + ` OFFSET $` + strconv.Itoa(argIndex+1)
+	args = append(args, pagination.Limit, pagination.Offset())
+
+	borrowings := []response.BorrowingResponse{}
+	err = borrowHandler.DB.Select(&borrowings, finalQuery, args...)
+	if err != nil {
+		log.Printf("GetAllBorrowings - Select error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to fetch borrowings")
+		return
+	}
+
+	next, prev := pagination.Links(request, total)
+
+	helper.SuccessResponse(writer, http.StatusOK, response.PaginatedResponse{
+		Data:       borrowings,
+		Page:       pagination.Page,
+		Limit:      pagination.Limit,
+		Total:      total,
+		TotalPages: pagination.TotalPages(total),
+		Next:       next,
+		Prev:       prev,
+	})
+}
+
+// parseBorrowingFilters turns the user_id, book_id, status, from and to query
+// parameters into WHERE conditions numbered from $1.
+func parseBorrowingFilters(query url.Values) ([]string, []interface{}, error) {
+	var args []interface{}
+	var conditions []string
+
+	argIndex := 1
+
+	for _, param := range []string{"user_id", "book_id"} {
+		value := query.Get(param)
+		if value == "" {
+			continue
+		}
+
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, nil, errors.New("Invalid " + param)
+		}
+		conditions = append(conditions, "br."+param+" = $"+strconv.Itoa(argIndex))
+		args = append(args, parsed)
+		argIndex++
+	}
+
+	switch query.Get("status") {
+	case "":
+	case "active":
+		conditions = append(conditions, "br.returned_at IS NULL AND br.lost_at IS NULL")
+	case "overdue":
+		conditions = append(conditions, "br.returned_at IS NULL AND br.lost_at IS NULL AND br.due_at < now()")
+	case "returned":
+		conditions = append(conditions, "br.returned_at IS NOT NULL")
+	case "lost":
+		conditions = append(conditions, "br.lost_at IS NOT NULL")
+	default:
+		return nil, nil, errors.New("Status must be one of active, overdue, returned, lost")
+	}
+
+	if from := query.Get("from"); from != "" {
+		fromDate, err := time.Parse("2006-01-02", from)
+		if err != nil {
+			return nil, nil, errors.New("from must be in YYYY-MM-DD format")
+		}
+		conditions = append(conditions, "br.borrowed_at >= $"+strconv.Itoa(argIndex))
+		args = append(args, fromDate)
+		argIndex++
+	}
+
+	if to := query.Get("to"); to != "" {
+		toDate, err := time.Parse("2006-01-02", to)
+		if err != nil {
+			return nil, nil, errors.New("to must be in YYYY-MM-DD format")
+		}
+		conditions = append(conditions, "br.borrowed_at < $"+strconv.Itoa(argIndex))
+		args = append(args, toDate.AddDate(0, 0, 1))
+		argIndex++
+	}
+
+	return conditions, args, nil
+}
+
+// validUserRoles are the roles users can have, so the only ones a loan
+// period can be set for.
+var validUserRoles = map[string]bool{
+	"user":  true,
+	"admin": true,
+}
+
+func (borrowHandler *BorrowHandler) SetLoanPeriod(writer http.ResponseWriter, request *http.Request) {
+	vars := mux.Vars(request)
+	role := vars["role"]
+
+	if !validUserRoles[role] {
+		helper.ErrorResponse(writer, http.StatusBadRequest, "Role must be one of user, admin")
+		return
+	}
+
+	var loanPeriodInput struct {
+		Days int `json:"days"`
+	}
+
+	err := json.NewDecoder(request.Body).Decode(&loanPeriodInput)
+	if err != nil {
+		log.Printf("SetLoanPeriod - JSON decode error: %v", err)
+		helper.ErrorResponse(writer, http.StatusBadRequest, "Invalid JSON format")
+		return
+	}
+
+	if loanPeriodInput.Days <= 0 {
+		helper.ErrorResponse(writer, http.StatusBadRequest, "Days must be greater than 0")
+		return
+	}
+
+	_, err = borrowHandler.DB.Exec(`
+		INSERT INTO loan_periods (role, days)
+		VALUES ($1, $2)
+		ON CONFLICT (role) DO UPDATE SET days = EXCLUDED.days
+	`, role, loanPeriodInput.Days)
+	if err != nil {
+		log.Printf("SetLoanPeriod - Upsert error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to set loan period")
+		return
+	}
+
+	helper.SuccessResponse(writer, http.StatusOK, map[string]interface{}{
+		"message": "Loan period updated successfully",
+	})
+}
+
+// loanPeriodDays resolves how many days a book may be kept: the category's
+// loan period wins, then the borrower's role, then DEFAULT_LOAN_PERIOD_DAYS.
+func loanPeriodDays(queryer sqlx.Queryer, bookId int, role string) (int, error) {
+	var days int
+	err := sqlx.Get(queryer, &days, `
+		SELECT COALESCE(c.loan_period_days, lp.days, $3)
+		FROM books b
+		LEFT JOIN categories c ON b.category_id = c.id
+		LEFT JOIN loan_periods lp ON lp.role = $2
+		WHERE b.id = $1
+	`, bookId, role, helper.GetEnvInt("DEFAULT_LOAN_PERIOD_DAYS", 14))
+	return days, err
+}
@@ -1,12 +1,16 @@
 package handlers
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/faqq11/lib-management/internal/helper"
+	"github.com/faqq11/lib-management/internal/models/response"
 	"github.com/gorilla/mux"
 	"github.com/jmoiron/sqlx"
 )
@@ -15,9 +19,41 @@ type CategoryHandler struct {
 	DB *sqlx.DB
 }
 
+type categoryInput struct {
+	Name           string `db:"name" json:"name"`
+	LoanPeriodDays *int   `db:"loan_period_days" json:"loan_period_days"`
+	FinePerDay     *int   `db:"fine_per_day" json:"fine_per_day"`
+	MaxFine        *int   `db:"max_fine" json:"max_fine"`
+}
+
+// categoryDescendantsQuery selects the id of the category given by the
+// placeholder and of every category below it.
+func categoryDescendantsQuery(placeholder string) string {
+	return `
+		WITH RECURSIVE subtree AS (
+			SELECT id FROM categories WHERE id = ` + placeholder + `
+			UNION ALL
+			SELECT c.id FROM categories c JOIN subtree s ON c.parent_id = s.id
+		)
+		SELECT id FROM subtree`
+}
+
+const categorySelectQuery = `
+		SELECT
+			c.id,
+			c.name,
+			c.parent_id,
+			c.loan_period_days,
+			c.fine_per_day,
+			c.max_fine,
+			(SELECT COUNT(*) FROM books b WHERE b.category_id = c.id) AS book_count
+		FROM categories c
+`
+
 func (categoryHandler *CategoryHandler) CreateCategory(writer http.ResponseWriter, request *http.Request) {
 	var categoryInput struct {
-		Name string `json:"name"`
+		categoryInput
+		ParentID *int `json:"parent_id"`
 	}
 
 	err := json.NewDecoder(request.Body).Decode(&categoryInput)
@@ -27,23 +63,291 @@ func (categoryHandler *CategoryHandler) CreateCategory(writer http.ResponseWrite
 		return
 	}
 
-	if categoryInput.Name == "" {
-		helper.ErrorResponse(writer, http.StatusBadRequest, "Category name required")
+	if message := categoryInput.validate(); message != "" {
+		helper.ErrorResponse(writer, http.StatusBadRequest, message)
 		return
 	}
 
-	_, err = categoryHandler.DB.Exec("INSERT INTO categories (name) VALUES ($1)", categoryInput.Name)
+	var categoryId int
+	err = categoryHandler.DB.Get(&categoryId, `
+		INSERT INTO categories (name, parent_id, loan_period_days, fine_per_day, max_fine)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id
+	`, categoryInput.Name, categoryInput.ParentID, categoryInput.LoanPeriodDays, categoryInput.FinePerDay, categoryInput.MaxFine)
 	if err != nil {
+		if helper.IsUniqueViolation(err) {
+			helper.ErrorResponse(writer, http.StatusConflict, "A category with this name already exists")
+			return
+		}
+		if helper.IsForeignKeyViolation(err) {
+			helper.ErrorResponse(writer, http.StatusBadRequest, "Parent category not found")
+			return
+		}
 		log.Printf("CreateCategory - Insert error: %v", err)
-		helper.ErrorResponse(writer, http.StatusInternalServerError, err.Error())
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to create category")
 		return
 	}
 
 	helper.SuccessResponse(writer, http.StatusCreated, map[string]interface{}{
 		"message": "Category created successfully",
+		"id":      categoryId,
+	})
+}
+
+func (categoryHandler *CategoryHandler) GetCategories(writer http.ResponseWriter, request *http.Request) {
+	categories := []response.CategoryResponse{}
+	err := categoryHandler.DB.Select(&categories, categorySelectQuery+` ORDER BY c.name`)
+	if err != nil {
+		log.Printf("GetCategories - Select error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to fetch categories")
+		return
+	}
+
+	helper.SuccessResponse(writer, http.StatusOK, categories)
+}
+
+// GetCategoryTree returns every category nested under its parent. Each node
+// counts the books filed directly in it and in the whole subtree.
+func (categoryHandler *CategoryHandler) GetCategoryTree(writer http.ResponseWriter, request *http.Request) {
+	var categories []response.CategoryResponse
+	err := categoryHandler.DB.Select(&categories, categorySelectQuery+` ORDER BY c.name`)
+	if err != nil {
+		log.Printf("GetCategoryTree - Select error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to fetch categories")
+		return
+	}
+
+	nodes := make(map[int]*response.CategoryTreeNode, len(categories))
+	for _, category := range categories {
+		nodes[category.ID] = &response.CategoryTreeNode{
+			CategoryResponse: category,
+			Children:         []*response.CategoryTreeNode{},
+		}
+	}
+
+	roots := []*response.CategoryTreeNode{}
+	for _, category := range categories {
+		node := nodes[category.ID]
+		if category.ParentID != nil {
+			if parent, ok := nodes[*category.ParentID]; ok {
+				parent.Children = append(parent.Children, node)
+				continue
+			}
+		}
+		roots = append(roots, node)
+	}
+
+	for _, root := range roots {
+		countSubtreeBooks(root)
+	}
+
+	helper.SuccessResponse(writer, http.StatusOK, roots)
+}
+
+func countSubtreeBooks(node *response.CategoryTreeNode) int {
+	node.TotalBookCount = node.BookCount
+	for _, child := range node.Children {
+		node.TotalBookCount += countSubtreeBooks(child)
+	}
+	return node.TotalBookCount
+}
+
+func (categoryHandler *CategoryHandler) GetCategoryById(writer http.ResponseWriter, request *http.Request) {
+	categoryId, ok := parseCategoryId(writer, request, "GetCategoryById")
+	if !ok {
+		return
+	}
+
+	var category response.CategoryDetailResponse
+	err := categoryHandler.DB.Get(&category, categorySelectQuery+` WHERE c.id = $1`, categoryId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			helper.ErrorResponse(writer, http.StatusNotFound, "Category not found")
+			return
+		}
+
+		log.Printf("GetCategoryById - Database error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	category.Books = []response.BookResponse{}
+	err = categoryHandler.DB.Select(&category.Books, bookSelectQuery+` WHERE b.category_id = $1 ORDER BY b.title`, categoryId)
+	if err != nil {
+		log.Printf("GetCategoryById - Books error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to fetch books")
+		return
+	}
+
+	helper.SuccessResponse(writer, http.StatusOK, category)
+}
+
+func (categoryHandler *CategoryHandler) UpdateCategory(writer http.ResponseWriter, request *http.Request) {
+	categoryId, ok := parseCategoryId(writer, request, "UpdateCategory")
+	if !ok {
+		return
+	}
+
+	var updateInput categoryInput
+
+	err := json.NewDecoder(request.Body).Decode(&updateInput)
+	if err != nil {
+		log.Printf("UpdateCategory - JSON decode error: %v", err)
+		helper.ErrorResponse(writer, http.StatusBadRequest, "Invalid JSON format")
+		return
+	}
+
+	if message := updateInput.validate(); message != "" {
+		helper.ErrorResponse(writer, http.StatusBadRequest, message)
+		return
+	}
+
+	tx, err := categoryHandler.DB.Beginx()
+	if err != nil {
+		log.Printf("UpdateCategory - Transaction start error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to start transaction")
+		return
+	}
+	defer tx.Rollback()
+
+	var current categoryInput
+	err = tx.Get(&current, `
+		SELECT name, loan_period_days, fine_per_day, max_fine
+		FROM categories
+		WHERE id = $1
+		FOR UPDATE
+	`, categoryId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			helper.ErrorResponse(writer, http.StatusNotFound, "Category not found")
+			return
+		}
+		log.Printf("UpdateCategory - Select error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to fetch category")
+		return
+	}
+
+	updateInput.keepUnset(current)
+
+	_, err = tx.Exec(`
+		UPDATE categories
+		SET name = $1,
+		    loan_period_days = $2,
+		    fine_per_day = $3,
+		    max_fine = $4
+		WHERE id = $5
+	`, updateInput.Name, updateInput.LoanPeriodDays, updateInput.FinePerDay, updateInput.MaxFine, categoryId)
+	if err != nil {
+		if helper.IsUniqueViolation(err) {
+			helper.ErrorResponse(writer, http.StatusConflict, "A category with this name already exists")
+			return
+		}
+		log.Printf("UpdateCategory - Update error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to update category")
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		log.Printf("UpdateCategory - Transaction commit error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to commit transaction")
+		return
+	}
+
+	helper.SuccessResponse(writer, http.StatusOK, map[string]string{
+		"message": "Category updated successfully",
+	})
+}
+
+// MoveCategory puts a category under another parent, or at the top level when
+// parent_id is null. A category cannot be moved under itself or one of its
+// own descendants.
+func (categoryHandler *CategoryHandler) MoveCategory(writer http.ResponseWriter, request *http.Request) {
+	categoryId, ok := parseCategoryId(writer, request, "MoveCategory")
+	if !ok {
+		return
+	}
+
+	var moveInput struct {
+		ParentID *int `json:"parent_id"`
+	}
+
+	err := json.NewDecoder(request.Body).Decode(&moveInput)
+	if err != nil {
+		log.Printf("MoveCategory - JSON decode error: %v", err)
+		helper.ErrorResponse(writer, http.StatusBadRequest, "Invalid JSON format")
+		return
+	}
+
+	tx, err := categoryHandler.DB.Beginx()
+	if err != nil {
+		log.Printf("MoveCategory - Transaction start error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to start transaction")
+		return
+	}
+	defer tx.Rollback()
+
+	// Moves are serialized so two of them cannot build a cycle together.
+	_, err = tx.Exec(`LOCK TABLE categories IN SHARE ROW EXCLUSIVE MODE`)
+	if err != nil {
+		log.Printf("MoveCategory - Lock error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to move category")
+		return
+	}
+
+	var exists bool
+	err = tx.Get(&exists, `SELECT EXISTS (SELECT 1 FROM categories WHERE id = $1)`, categoryId)
+	if err != nil {
+		log.Printf("MoveCategory - Category check error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+	if !exists {
+		helper.ErrorResponse(writer, http.StatusNotFound, "Category not found")
+		return
+	}
+
+	if moveInput.ParentID != nil {
+		var parentIsDescendant bool
+		err = tx.Get(&parentIsDescendant, `SELECT $2 IN (`+categoryDescendantsQuery("$1")+`)`, categoryId, *moveInput.ParentID)
+		if err != nil {
+			log.Printf("MoveCategory - Cycle check error: %v", err)
+			helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to move category")
+			return
+		}
+		if parentIsDescendant {
+			helper.ErrorResponse(writer, http.StatusConflict, "A category cannot be moved under itself or one of its subcategories")
+			return
+		}
+	}
+
+	_, err = tx.Exec(`UPDATE categories SET parent_id = $1 WHERE id = $2`, moveInput.ParentID, categoryId)
+	if err != nil {
+		if helper.IsForeignKeyViolation(err) {
+			helper.ErrorResponse(writer, http.StatusBadRequest, "Parent category not found")
+			return
+		}
+		log.Printf("MoveCategory - Update error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to move category")
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		log.Printf("MoveCategory - Transaction commit error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to commit transaction")
+		return
+	}
+
+	helper.SuccessResponse(writer, http.StatusOK, map[string]string{
+		"message": "Category moved successfully",
 	})
 }
 
+// DeleteCategory refuses to delete a category that still has books unless
+// replacement_id names a category to move them to, or force=true leaves them
+// uncategorized. Its circulation policies move to the replacement the way
+// MergeCategory moves them, and without one it is refused while it has any.
 func (categoryHandler *CategoryHandler) DeleteCategory(writer http.ResponseWriter, request *http.Request) {
 	vars := mux.Vars(request)
 	id := vars["id"]
@@ -55,20 +359,323 @@ func (categoryHandler *CategoryHandler) DeleteCategory(writer http.ResponseWrite
 		return
 	}
 
-	result, err := categoryHandler.DB.Exec("DELETE FROM categories WHERE id = $1", categoryId)
+	query := request.URL.Query()
+
+	var replacementId *int
+	if replacement := query.Get("replacement_id"); replacement != "" {
+		replacementInt, err := strconv.Atoi(replacement)
+		if err != nil {
+			helper.ErrorResponse(writer, http.StatusBadRequest, "Invalid replacement category ID")
+			return
+		}
+		if replacementInt == categoryId {
+			helper.ErrorResponse(writer, http.StatusBadRequest, "A category cannot replace itself")
+			return
+		}
+		replacementId = &replacementInt
+	}
+
+	force := false
+	if value := query.Get("force"); value != "" {
+		force, err = strconv.ParseBool(value)
+		if err != nil {
+			helper.ErrorResponse(writer, http.StatusBadRequest, "force must be true or false")
+			return
+		}
+	}
+
+	tx, err := categoryHandler.DB.Beginx()
+	if err != nil {
+		log.Printf("DeleteCategory - Transaction start error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to start transaction")
+		return
+	}
+	defer tx.Rollback()
+
+	// Locking the row keeps books from being filed under it until we are done.
+	err = tx.Get(&categoryId, `SELECT id FROM categories WHERE id = $1 FOR UPDATE`, categoryId)
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			helper.ErrorResponse(writer, http.StatusNotFound, "Category not found")
+			return
+		}
+		log.Printf("DeleteCategory - Select error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to delete category")
+		return
+	}
+
+	if replacementId != nil {
+		var replacementExists bool
+		err = tx.Get(&replacementExists, `SELECT EXISTS (SELECT 1 FROM categories WHERE id = $1)`, *replacementId)
+		if err != nil {
+			log.Printf("DeleteCategory - Replacement check error: %v", err)
+			helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to delete category")
+			return
+		}
+		if !replacementExists {
+			helper.ErrorResponse(writer, http.StatusBadRequest, "Replacement category not found")
+			return
+		}
+	}
+
+	var bookCount int
+	err = tx.Get(&bookCount, `SELECT COUNT(*) FROM books WHERE category_id = $1`, categoryId)
+	if err != nil {
+		log.Printf("DeleteCategory - Count error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to delete category")
+		return
+	}
+
+	var policyCount int
+	err = tx.Get(&policyCount, `SELECT COUNT(*) FROM circulation_policies WHERE category_id = $1`, categoryId)
+	if err != nil {
+		log.Printf("DeleteCategory - Policy count error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to delete category")
+		return
+	}
+
+	booksMoved := int64(0)
+	policiesMoved := int64(0)
+	if replacementId != nil {
+		booksMoved, err = moveCategoryBooks(tx, categoryId, *replacementId)
+		if err != nil {
+			log.Printf("DeleteCategory - Move books error: %v", err)
+			helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to delete category")
+			return
+		}
+
+		policiesMoved, err = moveCategoryPolicies(tx, categoryId, *replacementId)
+		if err != nil {
+			log.Printf("DeleteCategory - Move policies error: %v", err)
+			helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to delete category")
+			return
+		}
+	} else if bookCount > 0 && !force {
+		helper.ErrorResponseWithReason(writer, http.StatusConflict,
+			"Category still has books, pass replacement_id to move them or force=true to leave them uncategorized",
+			map[string]int{"book_count": bookCount})
+		return
+	} else if policyCount > 0 {
+		// Policies are not something to lose by accident, force or not.
+		helper.ErrorResponseWithReason(writer, http.StatusConflict,
+			"Category has circulation policies, pass replacement_id to move them or delete them first",
+			map[string]int{"policy_count": policyCount})
+		return
+	}
+
+	_, err = tx.Exec("DELETE FROM categories WHERE id = $1", categoryId)
+	if err != nil {
+		if helper.IsForeignKeyViolation(err) {
+			helper.ErrorResponse(writer, http.StatusConflict, "Category has subcategories, move or delete them first")
+			return
+		}
 		log.Printf("DeleteCategory - Delete error: %v", err)
-		helper.ErrorResponse(writer, http.StatusInternalServerError, err.Error())
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to delete category")
 		return
 	}
 
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		helper.ErrorResponse(writer, http.StatusNotFound, "Category not found")
+	err = tx.Commit()
+	if err != nil {
+		log.Printf("DeleteCategory - Transaction commit error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to commit transaction")
+		return
+	}
+
+	helper.SuccessResponse(writer, http.StatusOK, map[string]interface{}{
+		"message":        "Category deleted successfully",
+		"books_moved":    booksMoved,
+		"policies_moved": policiesMoved,
+	})
+}
+
+// MergeCategory moves the books, subcategories and circulation policies of a
+// category into target_id and deletes it, all in one transaction.
+func (categoryHandler *CategoryHandler) MergeCategory(writer http.ResponseWriter, request *http.Request) {
+	categoryId, ok := parseCategoryId(writer, request, "MergeCategory")
+	if !ok {
+		return
+	}
+
+	var mergeInput struct {
+		TargetID int `json:"target_id"`
+	}
+
+	err := json.NewDecoder(request.Body).Decode(&mergeInput)
+	if err != nil {
+		log.Printf("MergeCategory - JSON decode error: %v", err)
+		helper.ErrorResponse(writer, http.StatusBadRequest, "Invalid JSON format")
+		return
+	}
+
+	if mergeInput.TargetID == 0 {
+		helper.ErrorResponse(writer, http.StatusBadRequest, "target_id is required")
+		return
+	}
+
+	tx, err := categoryHandler.DB.Beginx()
+	if err != nil {
+		log.Printf("MergeCategory - Transaction start error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to start transaction")
+		return
+	}
+	defer tx.Rollback()
+
+	// Subcategories change parent, so this is serialized with moves.
+	_, err = tx.Exec(`LOCK TABLE categories IN SHARE ROW EXCLUSIVE MODE`)
+	if err != nil {
+		log.Printf("MergeCategory - Lock error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to merge category")
+		return
+	}
+
+	err = tx.Get(&categoryId, `SELECT id FROM categories WHERE id = $1 FOR UPDATE`, categoryId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			helper.ErrorResponse(writer, http.StatusNotFound, "Category not found")
+			return
+		}
+		log.Printf("MergeCategory - Select error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to merge category")
+		return
+	}
+
+	var targetExists bool
+	err = tx.Get(&targetExists, `SELECT EXISTS (SELECT 1 FROM categories WHERE id = $1)`, mergeInput.TargetID)
+	if err != nil {
+		log.Printf("MergeCategory - Target check error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+	if !targetExists {
+		helper.ErrorResponse(writer, http.StatusBadRequest, "Target category not found")
+		return
+	}
+
+	// Its subcategories move under the target, which must not be one of them.
+	var targetIsDescendant bool
+	err = tx.Get(&targetIsDescendant, `SELECT $2 IN (`+categoryDescendantsQuery("$1")+`)`, categoryId, mergeInput.TargetID)
+	if err != nil {
+		log.Printf("MergeCategory - Cycle check error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to merge category")
+		return
+	}
+	if targetIsDescendant {
+		helper.ErrorResponse(writer, http.StatusConflict, "A category cannot be merged into itself or one of its subcategories")
+		return
+	}
+
+	booksMoved, err := moveCategoryBooks(tx, categoryId, mergeInput.TargetID)
+	if err != nil {
+		log.Printf("MergeCategory - Move books error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to merge category")
+		return
+	}
+
+	_, err = tx.Exec(`UPDATE categories SET parent_id = $2 WHERE parent_id = $1`, categoryId, mergeInput.TargetID)
+	if err != nil {
+		log.Printf("MergeCategory - Move subcategories error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to merge category")
+		return
+	}
+
+	_, err = moveCategoryPolicies(tx, categoryId, mergeInput.TargetID)
+	if err != nil {
+		log.Printf("MergeCategory - Move policies error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to merge category")
+		return
+	}
+
+	_, err = tx.Exec(`DELETE FROM categories WHERE id = $1`, categoryId)
+	if err != nil {
+		log.Printf("MergeCategory - Delete error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to merge category")
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		log.Printf("MergeCategory - Transaction commit error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to commit transaction")
 		return
 	}
 
 	helper.SuccessResponse(writer, http.StatusOK, map[string]interface{}{
-		"message": "Category deleted successfully",
+		"message":     "Category merged successfully",
+		"books_moved": booksMoved,
 	})
 }
+
+// moveCategoryBooks files every book of category fromId under toId and
+// returns how many were moved.
+func moveCategoryBooks(tx *sqlx.Tx, fromId int, toId int) (int64, error) {
+	result, err := tx.Exec(`UPDATE books SET category_id = $2 WHERE category_id = $1`, fromId, toId)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// moveCategoryPolicies moves the circulation policies of category fromId to
+// toId and returns how many were moved. Policies toId already has its own
+// version of stay behind and are dropped with the category.
+func moveCategoryPolicies(tx *sqlx.Tx, fromId int, toId int) (int64, error) {
+	result, err := tx.Exec(`
+		UPDATE circulation_policies p
+		SET category_id = $2
+		WHERE p.category_id = $1
+		  AND NOT EXISTS (
+			SELECT 1 FROM circulation_policies t
+			WHERE t.category_id = $2 AND t.rule = p.rule AND t.role IS NOT DISTINCT FROM p.role
+		  )
+	`, fromId, toId)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// validate trims the name and checks the loan and fine settings, returning
+// the message to send back when one is invalid.
+func (categoryInput *categoryInput) validate() string {
+	categoryInput.Name = strings.TrimSpace(categoryInput.Name)
+	if categoryInput.Name == "" {
+		return "Category name required"
+	}
+
+	if categoryInput.LoanPeriodDays != nil && *categoryInput.LoanPeriodDays <= 0 {
+		return "Loan period days must be greater than 0"
+	}
+
+	if (categoryInput.FinePerDay != nil && *categoryInput.FinePerDay < 0) || (categoryInput.MaxFine != nil && *categoryInput.MaxFine < 0) {
+		return "Fine amounts cannot be negative"
+	}
+
+	return ""
+}
+
+// keepUnset fills the loan and fine settings left out of an update from the
+// category's current ones, so renaming a category does not clear them.
+func (categoryInput *categoryInput) keepUnset(current categoryInput) {
+	if categoryInput.LoanPeriodDays == nil {
+		categoryInput.LoanPeriodDays = current.LoanPeriodDays
+	}
+	if categoryInput.FinePerDay == nil {
+		categoryInput.FinePerDay = current.FinePerDay
+	}
+	if categoryInput.MaxFine == nil {
+		categoryInput.MaxFine = current.MaxFine
+	}
+}
+
+func parseCategoryId(writer http.ResponseWriter, request *http.Request, logPrefix string) (int, bool) {
+	id := mux.Vars(request)["id"]
+
+	categoryId, err := strconv.Atoi(id)
+	if err != nil {
+		log.Printf("%s - Invalid category ID: %s, error: %v", logPrefix, id, err)
+		helper.ErrorResponse(writer, http.StatusBadRequest, "Invalid category ID")
+		return 0, false
+	}
+
+	return categoryId, true
+}
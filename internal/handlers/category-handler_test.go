@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func intPointer(value int) *int {
+	return &value
+}
+
+func TestCategoryInputKeepUnset(t *testing.T) {
+	current := categoryInput{
+		Name:           "Reference",
+		LoanPeriodDays: intPointer(7),
+		FinePerDay:     intPointer(2000),
+		MaxFine:        intPointer(100000),
+	}
+
+	tests := []struct {
+		body string
+		want categoryInput
+	}{
+		{`{"name": "Reference Books"}`, categoryInput{
+			Name:           "Reference Books",
+			LoanPeriodDays: intPointer(7),
+			FinePerDay:     intPointer(2000),
+			MaxFine:        intPointer(100000),
+		}},
+		{`{"name": "Reference", "loan_period_days": 3, "max_fine": null}`, categoryInput{
+			Name:           "Reference",
+			LoanPeriodDays: intPointer(3),
+			FinePerDay:     intPointer(2000),
+			MaxFine:        intPointer(100000),
+		}},
+	}
+
+	for _, test := range tests {
+		var input categoryInput
+		err := json.Unmarshal([]byte(test.body), &input)
+		if err != nil {
+			t.Fatalf("Unmarshal(%s) returned error %v", test.body, err)
+		}
+
+		input.keepUnset(current)
+		if !reflect.DeepEqual(input, test.want) {
+			t.Errorf("keepUnset after %s = %+v, want %+v", test.body, input, test.want)
+		}
+	}
+}
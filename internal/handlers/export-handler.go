@@ -0,0 +1,263 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/faqq11/lib-management/internal/helper"
+	"github.com/faqq11/lib-management/internal/models/response"
+	"github.com/jmoiron/sqlx"
+)
+
+type ExportHandler struct {
+	DB *sqlx.DB
+}
+
+const (
+	exportFormatCSV   = "csv"
+	exportFormatExcel = "excel"
+	exportFormatJSONL = "jsonl"
+)
+
+// exportFlushRows is how many rows are written between flushes, so clients
+// start receiving data long before a big export is done.
+const exportFlushRows = 500
+
+var bookExportColumns = []string{
+	"id", "title", "author", "isbn10", "isbn13", "description", "publication_year",
+	"publisher", "edition", "language", "page_count", "work_id",
+	"call_number", "call_number_scheme", "room", "shelf",
+	"category_id", "category", "stock", "created_at",
+}
+
+var borrowingExportColumns = []string{
+	"id", "user_id", "username", "book_id", "book_title", "author", "borrowed_at", "due_at",
+	"renewal_count", "returned_at", "barcode", "checked_out_by", "checked_in_by", "status",
+}
+
+func (exportHandler *ExportHandler) ExportBooks(writer http.ResponseWriter, request *http.Request) {
+	format, err := parseExportFormat(request)
+	if err != nil {
+		helper.ErrorResponse(writer, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	rows, err := exportHandler.DB.Queryx(bookSelectQuery + ` ORDER BY b.id`)
+	if err != nil {
+		log.Printf("ExportBooks - Query error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to export books")
+		return
+	}
+	defer rows.Close()
+
+	streamExport(writer, "ExportBooks", rows, format, "books", bookExportColumns, func(book response.BookResponse) []string {
+		return []string{
+			strconv.Itoa(book.ID),
+			book.Title,
+			book.Author,
+			exportString(book.ISBN10),
+			exportString(book.ISBN13),
+			exportString(book.Description),
+			exportInt(book.PublicationYear),
+			exportString(book.Publisher),
+			exportString(book.Edition),
+			exportString(book.Language),
+			exportInt(book.PageCount),
+			exportInt(book.WorkID),
+			exportString(book.CallNumber),
+			exportString(book.CallNumberScheme),
+			exportString(book.Room),
+			exportString(book.Shelf),
+			exportInt(book.CategoryID),
+			exportString(book.Category),
+			strconv.Itoa(book.Stock),
+			book.CreatedAt.Format(time.RFC3339),
+		}
+	})
+}
+
+func (exportHandler *ExportHandler) ExportBorrowings(writer http.ResponseWriter, request *http.Request) {
+	format, err := parseExportFormat(request)
+	if err != nil {
+		helper.ErrorResponse(writer, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	conditions, args, err := parseBorrowingFilters(request.URL.Query())
+	if err != nil {
+		helper.ErrorResponse(writer, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	finalQuery := "SELECT " + borrowingListColumns + borrowingListFromClause
+	if len(conditions) > 0 {
+		finalQuery += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	finalQuery += " ORDER BY br.borrowed_at, br.id"
+
+	rows, err := exportHandler.DB.Queryx(finalQuery, args...)
+	if err != nil {
+		log.Printf("ExportBorrowings - Query error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to export borrowings")
+		return
+	}
+	defer rows.Close()
+
+	streamExport(writer, "ExportBorrowings", rows, format, "borrowings", borrowingExportColumns, func(borrowing response.BorrowingResponse) []string {
+		returnedAt := ""
+		if borrowing.ReturnedAt != nil {
+			returnedAt = borrowing.ReturnedAt.Format(time.RFC3339)
+		}
+
+		return []string{
+			strconv.Itoa(borrowing.ID),
+			strconv.Itoa(borrowing.UserID),
+			borrowing.Username,
+			strconv.Itoa(borrowing.BookID),
+			borrowing.BookTitle,
+			exportString(borrowing.Author),
+			borrowing.BorrowedAt.Format(time.RFC3339),
+			borrowing.DueAt.Format(time.RFC3339),
+			strconv.Itoa(borrowing.RenewalCount),
+			returnedAt,
+			exportString(borrowing.Barcode),
+			exportInt(borrowing.CheckedOutBy),
+			exportInt(borrowing.CheckedInBy),
+			borrowing.Status,
+		}
+	})
+}
+
+// streamExport writes rows one at a time as CSV or JSON Lines. JSON Lines uses
+// the same fields as the API responses; CSV uses columns and toRecord.
+// Once the first byte is sent the status can no longer change, so errors
+// after that are only logged and the download ends early.
+func streamExport[T any](writer http.ResponseWriter, logPrefix string, rows *sqlx.Rows, format string, filename string, columns []string, toRecord func(T) []string) {
+	flusher, _ := writer.(http.Flusher)
+
+	var csvWriter *csv.Writer
+	var jsonEncoder *json.Encoder
+
+	switch format {
+	case exportFormatJSONL:
+		writer.Header().Set("Content-Type", "application/x-ndjson")
+		writer.Header().Set("Content-Disposition", `attachment; filename="`+filename+`.jsonl"`)
+		writer.WriteHeader(http.StatusOK)
+		jsonEncoder = json.NewEncoder(writer)
+
+	default:
+		writer.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		writer.Header().Set("Content-Disposition", `attachment; filename="`+filename+`.csv"`)
+		writer.WriteHeader(http.StatusOK)
+
+		// Excel needs the byte order mark to read UTF-8 and expects CRLF.
+		if format == exportFormatExcel {
+			_, err := io.WriteString(writer, "\ufeff")
+			if err != nil {
+				log.Printf("%s - Write error: %v", logPrefix, err)
+				return
+			}
+		}
+
+		csvWriter = csv.NewWriter(writer)
+		csvWriter.UseCRLF = format == exportFormatExcel
+
+		err := csvWriter.Write(columns)
+		if err != nil {
+			log.Printf("%s - Write error: %v", logPrefix, err)
+			return
+		}
+	}
+
+	count := 0
+	for rows.Next() {
+		var row T
+		err := rows.StructScan(&row)
+		if err != nil {
+			log.Printf("%s - Scan error: %v", logPrefix, err)
+			return
+		}
+
+		if jsonEncoder != nil {
+			err = jsonEncoder.Encode(row)
+		} else {
+			record := toRecord(row)
+			if format == exportFormatExcel {
+				for i, value := range record {
+					record[i] = escapeSpreadsheetFormula(value)
+				}
+			}
+			err = csvWriter.Write(record)
+		}
+		if err != nil {
+			log.Printf("%s - Write error: %v", logPrefix, err)
+			return
+		}
+
+		count++
+		if count%exportFlushRows == 0 {
+			if csvWriter != nil {
+				csvWriter.Flush()
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+	}
+
+	err := rows.Err()
+	if err != nil {
+		log.Printf("%s - Rows error: %v", logPrefix, err)
+		return
+	}
+
+	if csvWriter != nil {
+		csvWriter.Flush()
+		err = csvWriter.Error()
+		if err != nil {
+			log.Printf("%s - Write error: %v", logPrefix, err)
+		}
+	}
+}
+
+func parseExportFormat(request *http.Request) (string, error) {
+	format := request.URL.Query().Get("format")
+	switch format {
+	case "":
+		return exportFormatCSV, nil
+	case exportFormatCSV, exportFormatExcel, exportFormatJSONL:
+		return format, nil
+	}
+
+	return "", errors.New("format must be one of csv, excel, jsonl")
+}
+
+// escapeSpreadsheetFormula stops spreadsheets from running cell values such
+// as titles that start with "=" as formulas.
+func escapeSpreadsheetFormula(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+func exportString(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
+
+func exportInt(value *int) string {
+	if value == nil {
+		return ""
+	}
+	return strconv.Itoa(*value)
+}
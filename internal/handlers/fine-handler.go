@@ -0,0 +1,515 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/faqq11/lib-management/internal/helper"
+	"github.com/faqq11/lib-management/internal/middleware"
+	"github.com/faqq11/lib-management/internal/models/response"
+	"github.com/gorilla/mux"
+	"github.com/jmoiron/sqlx"
+)
+
+type FineHandler struct {
+	DB *sqlx.DB
+}
+
+const fineSelectQuery = `
+	SELECT
+		f.id,
+		f.borrowing_id,
+		f.user_id,
+		u.username,
+		br.book_id,
+		b.title AS book_title,
+		f.type,
+		f.amount,
+		f.paid_amount,
+		f.status,
+		f.created_at,
+		f.updated_at
+	FROM fines f
+	JOIN borrowings br ON f.borrowing_id = br.id
+	JOIN books b ON br.book_id = b.id
+	JOIN users u ON f.user_id = u.id
+`
+
+func (fineHandler *FineHandler) GetUserFines(writer http.ResponseWriter, request *http.Request) {
+	user := request.Context().Value(middleware.UserContextKey)
+	if user == nil {
+		helper.ErrorResponse(writer, http.StatusUnauthorized, "User context not found")
+		return
+	}
+
+	userClaims := user.(middleware.UserClaims)
+	userId := userClaims.UserID
+
+	err := accrueOverdueFines(fineHandler.DB, userId)
+	if err != nil {
+		log.Printf("GetUserFines - Accrue fines error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to update fines")
+		return
+	}
+
+	var fines []response.FineResponse
+	err = fineHandler.DB.Select(&fines, fineSelectQuery+`
+		WHERE f.user_id = $1
+		ORDER BY f.created_at DESC
+	`, userId)
+	if err != nil {
+		log.Printf("GetUserFines - Select error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to fetch fines")
+		return
+	}
+
+	balance, err := outstandingBalance(fineHandler.DB, userId)
+	if err != nil {
+		log.Printf("GetUserFines - Balance error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to calculate balance")
+		return
+	}
+
+	helper.SuccessResponse(writer, http.StatusOK, map[string]interface{}{
+		"fines":   fines,
+		"balance": balance,
+	})
+}
+
+func (fineHandler *FineHandler) GetAllFines(writer http.ResponseWriter, request *http.Request) {
+	userIdParam := request.URL.Query().Get("user_id")
+	status := request.URL.Query().Get("status")
+
+	var args []interface{}
+	var conditions []string
+
+	argIndex := 1
+
+	if userIdParam != "" {
+		userId, err := strconv.Atoi(userIdParam)
+		if err != nil {
+			log.Printf("GetAllFines - Invalid user ID: %s, error: %v", userIdParam, err)
+			helper.ErrorResponse(writer, http.StatusBadRequest, "Invalid user ID")
+			return
+		}
+
+		// One user's fines are cheap to bring up to date, the whole
+		// library's are not.
+		err = accrueOverdueFines(fineHandler.DB, userId)
+		if err != nil {
+			log.Printf("GetAllFines - Accrue fines error: %v", err)
+			helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to update fines")
+			return
+		}
+
+		conditions = append(conditions, "f.user_id = $"+strconv.Itoa(argIndex))
+		args = append(args, userId)
+		argIndex++
+	}
+
+	if status != "" {
+		conditions = append(conditions, "f.status = $"+strconv.Itoa(argIndex))
+		args = append(args, status)
+		argIndex++
+	}
+
+	finalQuery := fineSelectQuery
+	if len(conditions) > 0 {
+		finalQuery += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	finalQuery += " ORDER BY f.created_at DESC"
+
+	var fines []response.FineResponse
+	err := fineHandler.DB.Select(&fines, finalQuery, args...)
+	if err != nil {
+		log.Printf("GetAllFines - Select error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to fetch fines")
+		return
+	}
+
+	helper.SuccessResponse(writer, http.StatusOK, fines)
+}
+
+func (fineHandler *FineHandler) PayFine(writer http.ResponseWriter, request *http.Request) {
+	user := request.Context().Value(middleware.UserContextKey)
+	if user == nil {
+		helper.ErrorResponse(writer, http.StatusUnauthorized, "User context not found")
+		return
+	}
+
+	staff := user.(middleware.UserClaims)
+
+	vars := mux.Vars(request)
+	id := vars["id"]
+
+	fineId, err := strconv.Atoi(id)
+	if err != nil {
+		log.Printf("PayFine - Invalid fine ID: %s, error: %v", id, err)
+		helper.ErrorResponse(writer, http.StatusBadRequest, "Invalid fine ID")
+		return
+	}
+
+	var paymentInput struct {
+		Amount int     `json:"amount"`
+		Note   *string `json:"note"`
+	}
+
+	err = json.NewDecoder(request.Body).Decode(&paymentInput)
+	if err != nil {
+		log.Printf("PayFine - JSON decode error: %v", err)
+		helper.ErrorResponse(writer, http.StatusBadRequest, "Invalid JSON format")
+		return
+	}
+
+	if paymentInput.Amount <= 0 {
+		helper.ErrorResponse(writer, http.StatusBadRequest, "Amount must be greater than 0")
+		return
+	}
+
+	tx, err := fineHandler.DB.Beginx()
+	if err != nil {
+		log.Printf("PayFine - Transaction start error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to start transaction")
+		return
+	}
+	defer tx.Rollback()
+
+	var fine struct {
+		Amount     int    `db:"amount"`
+		PaidAmount int    `db:"paid_amount"`
+		Status     string `db:"status"`
+	}
+
+	err = tx.Get(&fine, `SELECT amount, paid_amount, status FROM fines WHERE id = $1 FOR UPDATE`, fineId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			helper.ErrorResponse(writer, http.StatusNotFound, "Fine not found")
+			return
+		}
+		log.Printf("PayFine - Fetch fine error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to fetch fine")
+		return
+	}
+
+	if fine.Status != "outstanding" {
+		helper.ErrorResponse(writer, http.StatusBadRequest, "Fine is already "+fine.Status)
+		return
+	}
+
+	remaining := fine.Amount - fine.PaidAmount
+	if paymentInput.Amount > remaining {
+		helper.ErrorResponse(writer, http.StatusBadRequest, "Amount exceeds the outstanding fine of "+strconv.Itoa(remaining))
+		return
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO fine_payments (fine_id, kind, amount, note, recorded_by)
+		VALUES ($1, 'payment', $2, $3, $4)
+	`, fineId, paymentInput.Amount, paymentInput.Note, staff.UserID)
+	if err != nil {
+		log.Printf("PayFine - Insert payment error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to record payment")
+		return
+	}
+
+	_, err = tx.Exec(`
+		UPDATE fines
+		SET paid_amount = paid_amount + $1,
+		    status = CASE WHEN paid_amount + $1 >= amount THEN 'paid' ELSE 'outstanding' END,
+		    updated_at = now()
+		WHERE id = $2
+	`, paymentInput.Amount, fineId)
+	if err != nil {
+		log.Printf("PayFine - Update fine error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to update fine")
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		log.Printf("PayFine - Transaction commit error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to commit transaction")
+		return
+	}
+
+	helper.SuccessResponse(writer, http.StatusOK, map[string]interface{}{
+		"message":   "Payment recorded successfully",
+		"remaining": remaining - paymentInput.Amount,
+	})
+}
+
+func (fineHandler *FineHandler) WaiveFine(writer http.ResponseWriter, request *http.Request) {
+	user := request.Context().Value(middleware.UserContextKey)
+	if user == nil {
+		helper.ErrorResponse(writer, http.StatusUnauthorized, "User context not found")
+		return
+	}
+
+	staff := user.(middleware.UserClaims)
+
+	vars := mux.Vars(request)
+	id := vars["id"]
+
+	fineId, err := strconv.Atoi(id)
+	if err != nil {
+		log.Printf("WaiveFine - Invalid fine ID: %s, error: %v", id, err)
+		helper.ErrorResponse(writer, http.StatusBadRequest, "Invalid fine ID")
+		return
+	}
+
+	var waiveInput struct {
+		Note *string `json:"note"`
+	}
+
+	if request.ContentLength != 0 {
+		err = json.NewDecoder(request.Body).Decode(&waiveInput)
+		if err != nil {
+			log.Printf("WaiveFine - JSON decode error: %v", err)
+			helper.ErrorResponse(writer, http.StatusBadRequest, "Invalid JSON format")
+			return
+		}
+	}
+
+	tx, err := fineHandler.DB.Beginx()
+	if err != nil {
+		log.Printf("WaiveFine - Transaction start error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to start transaction")
+		return
+	}
+	defer tx.Rollback()
+
+	var fine struct {
+		Amount     int    `db:"amount"`
+		PaidAmount int    `db:"paid_amount"`
+		Status     string `db:"status"`
+	}
+
+	err = tx.Get(&fine, `SELECT amount, paid_amount, status FROM fines WHERE id = $1 FOR UPDATE`, fineId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			helper.ErrorResponse(writer, http.StatusNotFound, "Fine not found")
+			return
+		}
+		log.Printf("WaiveFine - Fetch fine error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to fetch fine")
+		return
+	}
+
+	if fine.Status != "outstanding" {
+		helper.ErrorResponse(writer, http.StatusBadRequest, "Fine is already "+fine.Status)
+		return
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO fine_payments (fine_id, kind, amount, note, recorded_by)
+		VALUES ($1, 'waiver', $2, $3, $4)
+	`, fineId, fine.Amount-fine.PaidAmount, waiveInput.Note, staff.UserID)
+	if err != nil {
+		log.Printf("WaiveFine - Insert waiver error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to record waiver")
+		return
+	}
+
+	_, err = tx.Exec(`UPDATE fines SET status = 'waived', updated_at = now() WHERE id = $1`, fineId)
+	if err != nil {
+		log.Printf("WaiveFine - Update fine error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to update fine")
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		log.Printf("WaiveFine - Transaction commit error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to commit transaction")
+		return
+	}
+
+	helper.SuccessResponse(writer, http.StatusOK, map[string]interface{}{
+		"message": "Fine waived successfully",
+	})
+}
+
+func (fineHandler *FineHandler) MarkLost(writer http.ResponseWriter, request *http.Request) {
+	vars := mux.Vars(request)
+	id := vars["id"]
+
+	borrowId, err := strconv.Atoi(id)
+	if err != nil {
+		log.Printf("MarkLost - Invalid borrow ID: %s, error: %v", id, err)
+		helper.ErrorResponse(writer, http.StatusBadRequest, "Invalid borrow ID")
+		return
+	}
+
+	tx, err := fineHandler.DB.Beginx()
+	if err != nil {
+		log.Printf("MarkLost - Transaction start error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to start transaction")
+		return
+	}
+	defer tx.Rollback()
+
+	var borrowData struct {
+		UserID int  `db:"user_id"`
+		ItemID *int `db:"item_id"`
+	}
+
+	err = tx.Get(&borrowData, `
+		SELECT user_id, item_id FROM borrowings
+		WHERE id = $1 AND returned_at IS NULL AND lost_at IS NULL
+		FOR UPDATE
+	`, borrowId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			helper.ErrorResponse(writer, http.StatusNotFound, "Borrowing record not found or already closed")
+			return
+		}
+		log.Printf("MarkLost - Fetch borrowing error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to fetch borrowing data")
+		return
+	}
+
+	_, err = tx.Exec(`UPDATE borrowings SET lost_at = now() WHERE id = $1`, borrowId)
+	if err != nil {
+		log.Printf("MarkLost - Update borrowing error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to update borrowing record")
+		return
+	}
+
+	if borrowData.ItemID != nil {
+		_, err = tx.Exec(`UPDATE items SET status = 'lost' WHERE id = $1`, *borrowData.ItemID)
+		if err != nil {
+			log.Printf("MarkLost - Update copy error: %v", err)
+			helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to update copy status")
+			return
+		}
+	}
+
+	userId := borrowData.UserID
+	lostFee := helper.GetEnvInt("LOST_ITEM_FEE", 100000)
+	_, err = tx.Exec(`
+		INSERT INTO fines (borrowing_id, user_id, type, amount)
+		VALUES ($1, $2, 'lost', $3)
+		ON CONFLICT (borrowing_id, type) DO NOTHING
+	`, borrowId, userId, lostFee)
+	if err != nil {
+		log.Printf("MarkLost - Insert fine error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to record lost item fine")
+		return
+	}
+
+	err = accrueOverdueFines(tx, userId)
+	if err != nil {
+		log.Printf("MarkLost - Accrue fines error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to update fines")
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		log.Printf("MarkLost - Transaction commit error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to commit transaction")
+		return
+	}
+
+	helper.SuccessResponse(writer, http.StatusOK, map[string]interface{}{
+		"message": "Borrowing marked as lost",
+		"fine":    lostFee,
+	})
+}
+
+// accrueOverdueFines brings a user's overdue fines up to date. It runs when a
+// book is returned or renewed and when one user's fines are read, never for
+// the whole library on a list. A fine grows by the category's daily rate
+// (FINE_PER_DAY by default) until it reaches the category cap (MAX_FINE by
+// default) and stops growing once the book is returned or lost. Waived fines
+// are left alone.
+func accrueOverdueFines(ext sqlx.Ext, userId int) error {
+	var overdue []struct {
+		BorrowingID   int `db:"borrowing_id"`
+		UserID        int `db:"user_id"`
+		DaysLate      int `db:"days_late"`
+		FinePerDay    int `db:"fine_per_day"`
+		MaxFine       int `db:"max_fine"`
+		AccruedAmount int `db:"accrued_amount"`
+	}
+	err := sqlx.Select(ext, &overdue, `
+		SELECT
+			br.id AS borrowing_id,
+			br.user_id,
+			CEIL(EXTRACT(EPOCH FROM (COALESCE(br.returned_at, br.lost_at, now()) - br.due_at)) / 86400)::int AS days_late,
+			COALESCE(c.fine_per_day, $2) AS fine_per_day,
+			COALESCE(c.max_fine, $3) AS max_fine,
+			COALESCE(f.accrued_amount, 0) AS accrued_amount
+		FROM borrowings br
+		JOIN books b ON br.book_id = b.id
+		LEFT JOIN categories c ON b.category_id = c.id
+		LEFT JOIN fines f ON f.borrowing_id = br.id AND f.type = 'overdue'
+		WHERE br.user_id = $1
+		  AND COALESCE(br.returned_at, br.lost_at, now()) > br.due_at
+		  AND (f.status IS NULL OR f.status <> 'waived')
+	`, userId, helper.GetEnvInt("FINE_PER_DAY", 1000), helper.GetEnvInt("MAX_FINE", 50000))
+	if err != nil {
+		return err
+	}
+
+	// The amount only ever grows, so a fine that is already paid off only
+	// goes back to outstanding when there is more to pay.
+	for _, borrowing := range overdue {
+		_, err = ext.Exec(`
+			INSERT INTO fines (borrowing_id, user_id, type, amount)
+			VALUES ($1, $2, 'overdue', $3)
+			ON CONFLICT (borrowing_id, type) DO UPDATE
+			SET amount = EXCLUDED.amount,
+			    status = CASE WHEN fines.paid_amount >= EXCLUDED.amount THEN 'paid' ELSE 'outstanding' END,
+			    updated_at = now()
+			WHERE fines.status <> 'waived' AND fines.amount < EXCLUDED.amount
+		`, borrowing.BorrowingID, borrowing.UserID, overdueFineAmount(
+			borrowing.AccruedAmount, borrowing.DaysLate, borrowing.FinePerDay, borrowing.MaxFine,
+		))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// overdueFineAmount is the overdue fine for a loan that is daysLate days past
+// its current due date, on top of accrued from loan periods before a
+// renewal. The cap is for the whole loan, but charges already made are never
+// taken back.
+func overdueFineAmount(accrued int, daysLate int, finePerDay int, maxFine int) int {
+	amount := accrued + daysLate*finePerDay
+	if amount > maxFine {
+		amount = maxFine
+	}
+	if amount < accrued {
+		amount = accrued
+	}
+	return amount
+}
+
+// settleOverdueFine keeps what a borrowing has been charged so far before its
+// due date moves, so lateness after a renewal adds to it.
+func settleOverdueFine(execer sqlx.Execer, borrowId int) error {
+	_, err := execer.Exec(`
+		UPDATE fines
+		SET accrued_amount = amount
+		WHERE borrowing_id = $1 AND type = 'overdue'
+	`, borrowId)
+	return err
+}
+
+func outstandingBalance(queryer sqlx.Queryer, userId int) (int, error) {
+	var balance int
+	err := sqlx.Get(queryer, &balance, `
+		SELECT COALESCE(SUM(amount - paid_amount), 0)
+		FROM fines
+		WHERE user_id = $1 AND status = 'outstanding'
+	`, userId)
+	return balance, err
+}
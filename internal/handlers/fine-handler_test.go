@@ -0,0 +1,31 @@
+package handlers
+
+import "testing"
+
+func TestOverdueFineAmount(t *testing.T) {
+	tests := []struct {
+		name       string
+		accrued    int
+		daysLate   int
+		finePerDay int
+		maxFine    int
+		want       int
+	}{
+		{"first period", 0, 5, 1000, 50000, 5000},
+		{"capped", 0, 60, 1000, 50000, 50000},
+		// Renewed after 5 days late, then 1 day late again.
+		{"late again after a renewal", 5000, 1, 1000, 50000, 6000},
+		{"renewed on time", 5000, 0, 1000, 50000, 5000},
+		{"cap across renewals", 45000, 10, 1000, 50000, 50000},
+		{"cap lowered after charging", 60000, 3, 1000, 50000, 60000},
+		{"free category", 0, 10, 0, 50000, 0},
+	}
+
+	for _, test := range tests {
+		got := overdueFineAmount(test.accrued, test.daysLate, test.finePerDay, test.maxFine)
+		if got != test.want {
+			t.Errorf("%s: overdueFineAmount(%d, %d, %d, %d) = %d, want %d",
+				test.name, test.accrued, test.daysLate, test.finePerDay, test.maxFine, got, test.want)
+		}
+	}
+}
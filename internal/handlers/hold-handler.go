@@ -0,0 +1,341 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/faqq11/lib-management/internal/helper"
+	"github.com/faqq11/lib-management/internal/middleware"
+	"github.com/faqq11/lib-management/internal/models/response"
+	"github.com/gorilla/mux"
+	"github.com/jmoiron/sqlx"
+)
+
+type HoldHandler struct {
+	DB *sqlx.DB
+}
+
+func (holdHandler *HoldHandler) PlaceHold(writer http.ResponseWriter, request *http.Request) {
+	user := request.Context().Value(middleware.UserContextKey)
+	if user == nil {
+		helper.ErrorResponse(writer, http.StatusUnauthorized, "User context not found")
+		return
+	}
+
+	userClaims := user.(middleware.UserClaims)
+	userId := userClaims.UserID
+
+	vars := mux.Vars(request)
+	id := vars["id"]
+
+	bookId, err := strconv.Atoi(id)
+	if err != nil {
+		log.Printf("PlaceHold - Invalid book ID: %s, error: %v", id, err)
+		helper.ErrorResponse(writer, http.StatusBadRequest, "Invalid book ID")
+		return
+	}
+
+	tx, err := holdHandler.DB.Beginx()
+	if err != nil {
+		log.Printf("PlaceHold - Transaction start error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to start transaction")
+		return
+	}
+	defer tx.Rollback()
+
+	err = expireHolds(tx, bookId)
+	if err != nil {
+		log.Printf("PlaceHold - Expire holds error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to update holds")
+		return
+	}
+
+	var stock int
+	err = tx.Get(&stock, `SELECT `+availableStockColumn+` FROM books b WHERE b.id = $1`, bookId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			helper.ErrorResponse(writer, http.StatusNotFound, "Book not found")
+			return
+		}
+		log.Printf("PlaceHold - Fetch stock error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to fetch stock")
+		return
+	}
+
+	if stock > 0 {
+		helper.ErrorResponse(writer, http.StatusBadRequest, "Book is available, borrow it instead")
+		return
+	}
+
+	var existingBorrow int
+	err = tx.Get(&existingBorrow, `
+		SELECT COUNT(*) FROM borrowings
+		WHERE user_id = $1 AND book_id = $2 AND returned_at IS NULL AND lost_at IS NULL
+	`, userId, bookId)
+	if err != nil {
+		log.Printf("PlaceHold - Check existing borrow error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to check existing borrow")
+		return
+	}
+
+	if existingBorrow > 0 {
+		helper.ErrorResponse(writer, http.StatusBadRequest, "You have already borrowed this book")
+		return
+	}
+
+	var existingHold int
+	err = tx.Get(&existingHold, `
+		SELECT COUNT(*) FROM holds
+		WHERE user_id = $1 AND book_id = $2 AND status IN ('waiting', 'ready')
+	`, userId, bookId)
+	if err != nil {
+		log.Printf("PlaceHold - Check existing hold error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to check existing hold")
+		return
+	}
+
+	if existingHold > 0 {
+		helper.ErrorResponse(writer, http.StatusBadRequest, "You already have a hold on this book")
+		return
+	}
+
+	var holdId int
+	err = tx.Get(&holdId, `
+		INSERT INTO holds (user_id, book_id)
+		VALUES ($1, $2)
+		RETURNING id
+	`, userId, bookId)
+	if err != nil {
+		log.Printf("PlaceHold - Insert hold error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to create hold")
+		return
+	}
+
+	var position int
+	err = tx.Get(&position, `
+		SELECT COUNT(*) FROM holds q
+		JOIN holds h ON h.id = $2
+		WHERE q.book_id = $1 AND q.status = 'waiting'
+		  AND (q.created_at, q.id) <= (h.created_at, h.id)
+	`, bookId, holdId)
+	if err != nil {
+		log.Printf("PlaceHold - Queue position error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to determine queue position")
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		log.Printf("PlaceHold - Transaction commit error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to commit transaction")
+		return
+	}
+
+	helper.SuccessResponse(writer, http.StatusCreated, map[string]interface{}{
+		"message":  "Hold placed successfully",
+		"id":       holdId,
+		"position": position,
+	})
+}
+
+func (holdHandler *HoldHandler) GetUserHolds(writer http.ResponseWriter, request *http.Request) {
+	user := request.Context().Value(middleware.UserContextKey)
+	if user == nil {
+		helper.ErrorResponse(writer, http.StatusUnauthorized, "User context not found")
+		return
+	}
+
+	userClaims := user.(middleware.UserClaims)
+	userId := userClaims.UserID
+
+	var holds []response.HoldResponse
+	err := holdHandler.DB.Select(&holds, `
+		SELECT
+			h.id,
+			h.book_id,
+			b.title AS book_title,
+		CASE
+			WHEN h.status = 'ready' AND h.expires_at < now() THEN 'expired'
+			ELSE h.status
+		END AS status,
+		CASE
+			WHEN h.status = 'waiting' THEN (
+				SELECT COUNT(*) FROM holds q
+				WHERE q.book_id = h.book_id AND q.status = 'waiting'
+				  AND (q.created_at, q.id) <= (h.created_at, h.id)
+			)
+		END AS position,
+			h.created_at,
+			h.ready_at,
+			h.expires_at
+		FROM holds h
+		JOIN books b ON h.book_id = b.id
+		WHERE h.user_id = $1
+		ORDER BY h.created_at DESC
+	`, userId)
+	if err != nil {
+		log.Printf("GetUserHolds - Select error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to fetch holds")
+		return
+	}
+
+	helper.SuccessResponse(writer, http.StatusOK, holds)
+}
+
+func (holdHandler *HoldHandler) CancelHold(writer http.ResponseWriter, request *http.Request) {
+	user := request.Context().Value(middleware.UserContextKey)
+	if user == nil {
+		helper.ErrorResponse(writer, http.StatusUnauthorized, "User context not found")
+		return
+	}
+
+	userClaims := user.(middleware.UserClaims)
+	userId := userClaims.UserID
+
+	vars := mux.Vars(request)
+	id := vars["id"]
+
+	holdId, err := strconv.Atoi(id)
+	if err != nil {
+		log.Printf("CancelHold - Invalid hold ID: %s, error: %v", id, err)
+		helper.ErrorResponse(writer, http.StatusBadRequest, "Invalid hold ID")
+		return
+	}
+
+	tx, err := holdHandler.DB.Beginx()
+	if err != nil {
+		log.Printf("CancelHold - Transaction start error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to start transaction")
+		return
+	}
+	defer tx.Rollback()
+
+	var holdData struct {
+		UserID int    `db:"user_id"`
+		BookID int    `db:"book_id"`
+		ItemID *int   `db:"item_id"`
+		Status string `db:"status"`
+	}
+
+	err = tx.Get(&holdData, `
+		SELECT user_id, book_id, item_id, status
+		FROM holds
+		WHERE id = $1 AND status IN ('waiting', 'ready')
+		FOR UPDATE
+	`, holdId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			helper.ErrorResponse(writer, http.StatusNotFound, "Hold not found or no longer active")
+			return
+		}
+		log.Printf("CancelHold - Fetch hold error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to fetch hold")
+		return
+	}
+
+	if holdData.UserID != userId {
+		helper.ErrorResponse(writer, http.StatusForbidden, "You can only cancel your own holds")
+		return
+	}
+
+	_, err = tx.Exec(`UPDATE holds SET status = 'cancelled' WHERE id = $1`, holdId)
+	if err != nil {
+		log.Printf("CancelHold - Update hold error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to cancel hold")
+		return
+	}
+
+	if holdData.Status == "ready" && holdData.ItemID != nil {
+		err = allocateCopy(tx, holdData.BookID, *holdData.ItemID)
+		if err != nil {
+			log.Printf("CancelHold - Allocate copy error: %v", err)
+			helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to release reserved copy")
+			return
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		log.Printf("CancelHold - Transaction commit error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to commit transaction")
+		return
+	}
+
+	helper.SuccessResponse(writer, http.StatusOK, map[string]interface{}{
+		"message": "Hold cancelled successfully",
+	})
+}
+
+// allocateCopy hands a copy that just became free to the next patron waiting
+// for the book, or puts it back on the shelf when nobody is waiting.
+func allocateCopy(tx *sqlx.Tx, bookId int, itemId int) error {
+	var nextHoldId int
+	err := tx.Get(&nextHoldId, `
+		SELECT id FROM holds
+		WHERE book_id = $1 AND status = 'waiting'
+		ORDER BY created_at, id
+		LIMIT 1
+		FOR UPDATE SKIP LOCKED
+	`, bookId)
+	if errors.Is(err, sql.ErrNoRows) {
+		_, err = tx.Exec(`UPDATE items SET status = 'available' WHERE id = $1`, itemId)
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	readyAt := time.Now()
+	expiresAt := readyAt.AddDate(0, 0, helper.GetEnvInt("HOLD_PICKUP_DAYS", 3))
+
+	_, err = tx.Exec(`
+		UPDATE holds
+		SET status = 'ready', item_id = $1, ready_at = $2, expires_at = $3
+		WHERE id = $4
+	`, itemId, readyAt, expiresAt, nextHoldId)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`UPDATE items SET status = 'on_hold' WHERE id = $1`, itemId)
+	return err
+}
+
+// expireHolds closes ready holds whose pickup window has passed and passes
+// their reserved copies down the queue.
+func expireHolds(tx *sqlx.Tx, bookId int) error {
+	var expired []struct {
+		ID     int  `db:"id"`
+		ItemID *int `db:"item_id"`
+	}
+	err := tx.Select(&expired, `
+		SELECT id, item_id FROM holds
+		WHERE book_id = $1 AND status = 'ready' AND expires_at < now()
+		FOR UPDATE
+	`, bookId)
+	if err != nil {
+		return err
+	}
+
+	for _, hold := range expired {
+		_, err = tx.Exec(`UPDATE holds SET status = 'expired' WHERE id = $1`, hold.ID)
+		if err != nil {
+			return err
+		}
+
+		if hold.ItemID == nil {
+			continue
+		}
+
+		err = allocateCopy(tx, bookId, *hold.ItemID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
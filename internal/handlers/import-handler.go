@@ -0,0 +1,488 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/faqq11/lib-management/internal/autocomplete"
+	"github.com/faqq11/lib-management/internal/helper"
+	"github.com/faqq11/lib-management/internal/models"
+	"github.com/faqq11/lib-management/internal/models/response"
+	"github.com/jmoiron/sqlx"
+)
+
+type ImportHandler struct {
+	DB           *sqlx.DB
+	Autocomplete *autocomplete.Index
+}
+
+const maxImportUploadBytes = 32 << 20
+
+// catalogRecord is one book to import, whatever format it came from.
+type catalogRecord struct {
+	Row      int
+	Book     models.Book
+	Category string
+	Copies   int
+	Errors   []string
+}
+
+// importRowError is a problem with the row itself, reported back to the
+// caller as is.
+type importRowError string
+
+func (err importRowError) Error() string {
+	return string(err)
+}
+
+var csvImportColumns = map[string]bool{
+	"title":            true,
+	"author":           true,
+	"isbn":             true,
+	"description":      true,
+	"publication_year": true,
+	"publisher":        true,
+	"edition":          true,
+	"language":         true,
+	"page_count":       true,
+	"call_number":      true,
+	"room":             true,
+	"shelf":            true,
+	"category":         true,
+	"copies":           true,
+}
+
+func (importHandler *ImportHandler) ImportCSV(writer http.ResponseWriter, request *http.Request) {
+	dryRun, createCategories, err := parseImportOptions(request)
+	if err != nil {
+		helper.ErrorResponse(writer, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	request.Body = http.MaxBytesReader(writer, request.Body, maxImportUploadBytes)
+	file, _, err := request.FormFile("file")
+	if err != nil {
+		log.Printf("ImportCSV - Form file error: %v", err)
+		helper.ErrorResponse(writer, http.StatusBadRequest, "A CSV file is required in the file field")
+		return
+	}
+	defer file.Close()
+
+	records, err := parseCatalogCSV(file)
+	if err != nil {
+		helper.ErrorResponse(writer, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	importHandler.importRecords(writer, "ImportCSV", records, dryRun, createCategories)
+}
+
+// importRecords saves records in a single transaction and writes the per-row
+// report. Every row runs inside its own savepoint, so a rejected row does not
+// undo the others. A dry run rolls everything back at the end.
+func (importHandler *ImportHandler) importRecords(writer http.ResponseWriter, logPrefix string, records []catalogRecord, dryRun bool, createCategories bool) {
+	if len(records) == 0 {
+		helper.ErrorResponse(writer, http.StatusBadRequest, "The file has no records")
+		return
+	}
+
+	tx, err := importHandler.DB.Beginx()
+	if err != nil {
+		log.Printf("%s - Transaction start error: %v", logPrefix, err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to start transaction")
+		return
+	}
+	defer tx.Rollback()
+
+	report := response.ImportReport{
+		DryRun: dryRun,
+		Rows:   make([]response.ImportRowResult, 0, len(records)),
+	}
+
+	for _, record := range records {
+		result := response.ImportRowResult{
+			Row:    record.Row,
+			Title:  record.Book.Title,
+			Errors: record.Errors,
+		}
+
+		if len(record.Errors) == 0 {
+			_, err = tx.Exec(`SAVEPOINT import_row`)
+			if err != nil {
+				log.Printf("%s - Savepoint error: %v", logPrefix, err)
+				helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to import records")
+				return
+			}
+
+			bookId, created, err := upsertCatalogRecord(tx, record, createCategories)
+			if err != nil {
+				_, rollbackErr := tx.Exec(`ROLLBACK TO SAVEPOINT import_row`)
+				if rollbackErr != nil {
+					log.Printf("%s - Rollback to savepoint error: %v", logPrefix, rollbackErr)
+					helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to import records")
+					return
+				}
+
+				var rowErr importRowError
+				if errors.As(err, &rowErr) {
+					result.Errors = []string{rowErr.Error()}
+				} else if errors.Is(err, errAuthorNotFound) {
+					result.Errors = []string{err.Error()}
+				} else if helper.IsUniqueViolation(err) {
+					result.Errors = []string{"A book with this ISBN already exists"}
+				} else {
+					log.Printf("%s - Row %d error: %v", logPrefix, record.Row, err)
+					result.Errors = []string{"Failed to save this row"}
+				}
+			} else {
+				_, err = tx.Exec(`RELEASE SAVEPOINT import_row`)
+				if err != nil {
+					log.Printf("%s - Release savepoint error: %v", logPrefix, err)
+					helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to import records")
+					return
+				}
+
+				result.Status = "updated"
+				if created {
+					result.Status = "created"
+				}
+				if !dryRun || !created {
+					result.BookID = &bookId
+				}
+			}
+		}
+
+		switch {
+		case len(result.Errors) > 0:
+			result.Status = "rejected"
+			report.Rejected++
+		case result.Status == "created":
+			report.Created++
+		default:
+			report.Updated++
+		}
+
+		report.Rows = append(report.Rows, result)
+	}
+
+	if dryRun {
+		helper.SuccessResponse(writer, http.StatusOK, report)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		log.Printf("%s - Transaction commit error: %v", logPrefix, err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to commit transaction")
+		return
+	}
+
+	var bookIds []int
+	for _, row := range report.Rows {
+		if row.BookID != nil {
+			bookIds = append(bookIds, *row.BookID)
+		}
+	}
+	refreshAutocomplete(importHandler.Autocomplete, importHandler.DB, bookIds...)
+
+	helper.SuccessResponse(writer, http.StatusOK, report)
+}
+
+// upsertCatalogRecord creates the book, or updates it when findExistingBook
+// already knows it, then adds the record's copies.
+func upsertCatalogRecord(tx *sqlx.Tx, record catalogRecord, createCategories bool) (int, bool, error) {
+	book := record.Book
+
+	if record.Category != "" {
+		categoryId, err := resolveImportCategory(tx, record.Category, createCategories)
+		if err != nil {
+			return 0, false, err
+		}
+		book.CategoryID = &categoryId
+	}
+
+	bookId, err := findExistingBook(tx, book)
+	if errors.Is(err, sql.ErrNoRows) {
+		var workId int
+		workId, err = resolveBookWork(tx, book)
+		if err != nil {
+			return 0, false, err
+		}
+
+		err = tx.Get(&bookId, `
+			INSERT INTO books (title, author, isbn10, isbn13, description, publication_year, publisher, edition, language, page_count, work_id,
+				call_number, call_number_scheme, call_number_sort, room, shelf, category_id)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)
+			RETURNING id
+		`, book.Title, book.Author, book.ISBN10, book.ISBN13, book.Description, book.PublicationYear,
+			book.Publisher, book.Edition, book.Language, book.PageCount, workId,
+			book.CallNumber, book.CallNumberScheme, book.CallNumberSort, book.Room, book.Shelf, book.CategoryID)
+		if err != nil {
+			return 0, false, err
+		}
+
+		contributors := book.Contributors
+		if contributors == nil {
+			contributors = contributorsFromAuthor(book.Author)
+		}
+
+		err = setBookContributors(tx, bookId, contributors)
+		if err != nil {
+			return 0, false, err
+		}
+
+		_, err = tx.Exec(`
+			INSERT INTO items (book_id)
+			SELECT $1 FROM generate_series(1, $2)
+		`, bookId, record.Copies)
+		if err != nil {
+			return 0, false, err
+		}
+
+		return bookId, true, nil
+	} else if err != nil {
+		return 0, false, err
+	}
+
+	// A record without authors leaves the book's authors alone.
+	hasAuthors := book.Author != "" || len(book.Contributors) > 0
+
+	var previousAuthor string
+	if hasAuthors {
+		err = tx.Get(&previousAuthor, `SELECT coalesce(author, '') FROM books WHERE id = $1`, bookId)
+		if err != nil {
+			return 0, false, err
+		}
+	}
+
+	// Only overwrite what the record actually has.
+	_, err = tx.Exec(`
+		UPDATE books
+		SET title = $1,
+		    author = coalesce(nullif($2, ''), author),
+		    isbn10 = coalesce($3, isbn10),
+		    isbn13 = coalesce($4, isbn13),
+		    description = coalesce($5, description),
+		    publication_year = coalesce($6, publication_year),
+		    publisher = coalesce($7, publisher),
+		    edition = coalesce($8, edition),
+		    language = coalesce($9, language),
+		    page_count = coalesce($10, page_count),
+		    call_number = coalesce($11, call_number),
+		    call_number_scheme = coalesce($12, call_number_scheme),
+		    call_number_sort = coalesce($13, call_number_sort),
+		    room = coalesce($14, room),
+		    shelf = coalesce($15, shelf),
+		    category_id = coalesce($16, category_id)
+		WHERE id = $17
+	`, book.Title, book.Author, book.ISBN10, book.ISBN13, book.Description, book.PublicationYear,
+		book.Publisher, book.Edition, book.Language, book.PageCount,
+		book.CallNumber, book.CallNumberScheme, book.CallNumberSort, book.Room, book.Shelf, book.CategoryID, bookId)
+	if err != nil {
+		return 0, false, err
+	}
+
+	if hasAuthors {
+		err = updateBookContributors(tx, bookId, previousAuthor, book)
+		if err != nil {
+			return 0, false, err
+		}
+	}
+
+	// Copies of a book that is already on loan may be waited for.
+	for i := 0; i < record.Copies; i++ {
+		var itemId int
+		err = tx.Get(&itemId, `INSERT INTO items (book_id) VALUES ($1) RETURNING id`, bookId)
+		if err != nil {
+			return 0, false, err
+		}
+
+		err = allocateCopy(tx, bookId, itemId)
+		if err != nil {
+			return 0, false, err
+		}
+	}
+
+	return bookId, false, nil
+}
+
+func resolveImportCategory(tx *sqlx.Tx, name string, createCategories bool) (int, error) {
+	var categoryId int
+	err := tx.Get(&categoryId, `SELECT id FROM categories WHERE lower(name) = lower($1)`, name)
+	if err == nil {
+		return categoryId, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return 0, err
+	}
+
+	if !createCategories {
+		return 0, importRowError("Category " + strconv.Quote(name) + " does not exist")
+	}
+
+	err = tx.Get(&categoryId, `INSERT INTO categories (name) VALUES ($1) RETURNING id`, name)
+	return categoryId, err
+}
+
+func parseImportOptions(request *http.Request) (dryRun bool, createCategories bool, err error) {
+	query := request.URL.Query()
+
+	if value := query.Get("dry_run"); value != "" {
+		dryRun, err = strconv.ParseBool(value)
+		if err != nil {
+			return false, false, errors.New("dry_run must be true or false")
+		}
+	}
+
+	if value := query.Get("create_categories"); value != "" {
+		createCategories, err = strconv.ParseBool(value)
+		if err != nil {
+			return false, false, errors.New("create_categories must be true or false")
+		}
+	}
+
+	return dryRun, createCategories, nil
+}
+
+// parseCatalogCSV reads a CSV with a header row naming its columns. Row level
+// problems are kept on the record so they end up in the report; only a file
+// that cannot be read at all is an error.
+func parseCatalogCSV(file io.Reader) ([]catalogRecord, error) {
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, errors.New("Failed to read the CSV header")
+	}
+
+	columns := make(map[string]int)
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if !csvImportColumns[name] {
+			return nil, errors.New("Unknown CSV column " + strconv.Quote(name))
+		}
+		columns[name] = i
+	}
+
+	if _, ok := columns["title"]; !ok {
+		return nil, errors.New("The CSV must have a title column")
+	}
+
+	var records []catalogRecord
+	for {
+		fields, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.New("Failed to parse CSV: " + err.Error())
+		}
+
+		line, _ := reader.FieldPos(0)
+		value := func(column string) string {
+			index, ok := columns[column]
+			if !ok || index >= len(fields) {
+				return ""
+			}
+			return strings.TrimSpace(fields[index])
+		}
+
+		// A row without copies adds one, like creating a book does.
+		record := catalogRecord{
+			Row:      line,
+			Category: value("category"),
+			Copies:   1,
+		}
+		record.Book.Title = value("title")
+		record.Book.Author = value("author")
+
+		if isbn := value("isbn"); isbn != "" {
+			record.Book.ISBN13 = &isbn
+		}
+		if description := value("description"); description != "" {
+			record.Book.Description = &description
+		}
+		if year := value("publication_year"); year != "" {
+			yearInt, err := strconv.Atoi(year)
+			if err != nil {
+				record.Errors = append(record.Errors, "publication_year must be a number")
+			} else {
+				record.Book.PublicationYear = &yearInt
+			}
+		}
+		if publisher := value("publisher"); publisher != "" {
+			record.Book.Publisher = &publisher
+		}
+		if edition := value("edition"); edition != "" {
+			record.Book.Edition = &edition
+		}
+		if language := value("language"); language != "" {
+			record.Book.Language = &language
+		}
+		if pageCount := value("page_count"); pageCount != "" {
+			pageCountInt, err := strconv.Atoi(pageCount)
+			if err != nil {
+				record.Errors = append(record.Errors, "page_count must be a number")
+			} else {
+				record.Book.PageCount = &pageCountInt
+			}
+		}
+		if callNumber := value("call_number"); callNumber != "" {
+			record.Book.CallNumber = &callNumber
+		}
+		if room := value("room"); room != "" {
+			record.Book.Room = &room
+		}
+		if shelf := value("shelf"); shelf != "" {
+			record.Book.Shelf = &shelf
+		}
+		if copies := value("copies"); copies != "" {
+			copiesInt, err := strconv.Atoi(copies)
+			if err != nil || copiesInt < 0 {
+				record.Errors = append(record.Errors, "copies must be a non-negative number")
+			} else {
+				record.Copies = copiesInt
+			}
+		}
+
+		record.validate()
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+// validate checks the fields every import format shares and normalizes the
+// ISBN in place.
+func (record *catalogRecord) validate() {
+	if record.Book.Title == "" {
+		record.Errors = append(record.Errors, "Title is required")
+	}
+
+	err := normalizeBookISBN(&record.Book)
+	if err != nil {
+		record.Errors = append(record.Errors, err.Error())
+	}
+
+	err = normalizeEditionFields(&record.Book)
+	if err != nil {
+		record.Errors = append(record.Errors, err.Error())
+	}
+
+	err = normalizeShelfLocation(&record.Book)
+	if err != nil {
+		record.Errors = append(record.Errors, err.Error())
+	}
+
+	if message := validateContributors(record.Book.Contributors); message != "" {
+		record.Errors = append(record.Errors, message)
+	}
+}
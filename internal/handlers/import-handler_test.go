@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseCatalogCSVCopies(t *testing.T) {
+	tests := []struct {
+		csv  string
+		want []int
+	}{
+		{"title\nBumi Manusia\n", []int{1}},
+		{"title,copies\nBumi Manusia,\nLaskar Pelangi,3\nCantik Itu Luka,0\n", []int{1, 3, 0}},
+	}
+
+	for _, test := range tests {
+		records, err := parseCatalogCSV(strings.NewReader(test.csv))
+		if err != nil {
+			t.Fatalf("parseCatalogCSV(%q) returned error %v", test.csv, err)
+		}
+		if len(records) != len(test.want) {
+			t.Fatalf("parseCatalogCSV(%q) returned %d records, want %d", test.csv, len(records), len(test.want))
+		}
+		for i, record := range records {
+			if record.Copies != test.want[i] {
+				t.Errorf("parseCatalogCSV(%q) row %d copies = %d, want %d", test.csv, record.Row, record.Copies, test.want[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,399 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/faqq11/lib-management/internal/helper"
+	"github.com/faqq11/lib-management/internal/models/response"
+	"github.com/gorilla/mux"
+	"github.com/jmoiron/sqlx"
+)
+
+type ItemHandler struct {
+	DB *sqlx.DB
+}
+
+var validItemConditions = map[string]bool{
+	"new":     true,
+	"good":    true,
+	"fair":    true,
+	"poor":    true,
+	"damaged": true,
+}
+
+// Statuses an admin may set by hand. on_loan and on_hold are only ever set by
+// borrowing and holds.
+var manualItemStatuses = map[string]bool{
+	"available": true,
+	"damaged":   true,
+	"lost":      true,
+	"withdrawn": true,
+}
+
+const itemSelectQuery = `
+	SELECT
+		i.id,
+		i.book_id,
+		b.title AS book_title,
+		i.barcode,
+		i.condition,
+		i.status,
+		i.location,
+		i.acquired_at,
+		i.created_at
+	FROM items i
+	JOIN books b ON i.book_id = b.id
+`
+
+func (itemHandler *ItemHandler) AddItems(writer http.ResponseWriter, request *http.Request) {
+	vars := mux.Vars(request)
+	id := vars["id"]
+
+	bookId, err := strconv.Atoi(id)
+	if err != nil {
+		log.Printf("AddItems - Invalid book ID: %s, error: %v", id, err)
+		helper.ErrorResponse(writer, http.StatusBadRequest, "Invalid book ID")
+		return
+	}
+
+	var itemInput struct {
+		Barcode    *string `json:"barcode"`
+		Condition  string  `json:"condition"`
+		Location   *string `json:"location"`
+		AcquiredAt *string `json:"acquired_at"`
+		Copies     int     `json:"copies"`
+	}
+
+	err = json.NewDecoder(request.Body).Decode(&itemInput)
+	if err != nil {
+		log.Printf("AddItems - JSON decode error: %v", err)
+		helper.ErrorResponse(writer, http.StatusBadRequest, "Invalid JSON format")
+		return
+	}
+
+	if itemInput.Copies <= 0 {
+		itemInput.Copies = 1
+	}
+
+	if itemInput.Barcode != nil && itemInput.Copies > 1 {
+		helper.ErrorResponse(writer, http.StatusBadRequest, "A barcode can only be given when adding a single copy")
+		return
+	}
+
+	if itemInput.Condition == "" {
+		itemInput.Condition = "good"
+	}
+
+	if !validItemConditions[itemInput.Condition] {
+		helper.ErrorResponse(writer, http.StatusBadRequest, "Invalid condition")
+		return
+	}
+
+	acquiredAt := time.Now()
+	if itemInput.AcquiredAt != nil {
+		acquiredAt, err = time.Parse("2006-01-02", *itemInput.AcquiredAt)
+		if err != nil {
+			helper.ErrorResponse(writer, http.StatusBadRequest, "acquired_at must be in YYYY-MM-DD format")
+			return
+		}
+	}
+
+	tx, err := itemHandler.DB.Beginx()
+	if err != nil {
+		log.Printf("AddItems - Transaction start error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to start transaction")
+		return
+	}
+	defer tx.Rollback()
+
+	var bookExists bool
+	err = tx.Get(&bookExists, `SELECT EXISTS (SELECT 1 FROM books WHERE id = $1)`, bookId)
+	if err != nil {
+		log.Printf("AddItems - Check book error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to fetch book")
+		return
+	}
+
+	if !bookExists {
+		helper.ErrorResponse(writer, http.StatusNotFound, "Book not found")
+		return
+	}
+
+	var barcodes []string
+	for i := 0; i < itemInput.Copies; i++ {
+		var item struct {
+			ID      int    `db:"id"`
+			Barcode string `db:"barcode"`
+		}
+
+		err = tx.Get(&item, `
+			INSERT INTO items (book_id, barcode, condition, location, acquired_at, status)
+			VALUES ($1, COALESCE($2, 'LIB' || lpad(nextval('item_barcode_seq')::text, 8, '0')), $3, $4, $5, 'available')
+			RETURNING id, barcode
+		`, bookId, itemInput.Barcode, itemInput.Condition, itemInput.Location, acquiredAt)
+		if err != nil {
+			if helper.IsUniqueViolation(err) {
+				helper.ErrorResponse(writer, http.StatusConflict, "Barcode already exists")
+				return
+			}
+			log.Printf("AddItems - Insert error: %v", err)
+			helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to add copy")
+			return
+		}
+
+		err = allocateCopy(tx, bookId, item.ID)
+		if err != nil {
+			log.Printf("AddItems - Allocate copy error: %v", err)
+			helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to allocate copy")
+			return
+		}
+
+		barcodes = append(barcodes, item.Barcode)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		log.Printf("AddItems - Transaction commit error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to commit transaction")
+		return
+	}
+
+	helper.SuccessResponse(writer, http.StatusCreated, map[string]interface{}{
+		"message":  "Copies added successfully",
+		"barcodes": barcodes,
+	})
+}
+
+func (itemHandler *ItemHandler) GetBookItems(writer http.ResponseWriter, request *http.Request) {
+	vars := mux.Vars(request)
+	id := vars["id"]
+
+	bookId, err := strconv.Atoi(id)
+	if err != nil {
+		log.Printf("GetBookItems - Invalid book ID: %s, error: %v", id, err)
+		helper.ErrorResponse(writer, http.StatusBadRequest, "Invalid book ID")
+		return
+	}
+
+	var items []response.ItemResponse
+	err = itemHandler.DB.Select(&items, itemSelectQuery+`
+		WHERE i.book_id = $1
+		ORDER BY i.id
+	`, bookId)
+	if err != nil {
+		log.Printf("GetBookItems - Select error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to fetch copies")
+		return
+	}
+
+	helper.SuccessResponse(writer, http.StatusOK, items)
+}
+
+func (itemHandler *ItemHandler) GetItemByBarcode(writer http.ResponseWriter, request *http.Request) {
+	vars := mux.Vars(request)
+	barcode := vars["barcode"]
+
+	var item response.ItemResponse
+	err := itemHandler.DB.Get(&item, itemSelectQuery+`WHERE i.barcode = $1`, barcode)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			helper.ErrorResponse(writer, http.StatusNotFound, "Copy not found")
+			return
+		}
+		log.Printf("GetItemByBarcode - Select error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	helper.SuccessResponse(writer, http.StatusOK, item)
+}
+
+func (itemHandler *ItemHandler) UpdateItem(writer http.ResponseWriter, request *http.Request) {
+	vars := mux.Vars(request)
+	id := vars["id"]
+
+	itemId, err := strconv.Atoi(id)
+	if err != nil {
+		log.Printf("UpdateItem - Invalid ID: %s, error: %v", id, err)
+		helper.ErrorResponse(writer, http.StatusBadRequest, "Invalid copy ID")
+		return
+	}
+
+	var itemInput struct {
+		Condition *string `json:"condition"`
+		Status    *string `json:"status"`
+		Location  *string `json:"location"`
+	}
+
+	err = json.NewDecoder(request.Body).Decode(&itemInput)
+	if err != nil {
+		log.Printf("UpdateItem - JSON decode error: %v", err)
+		helper.ErrorResponse(writer, http.StatusBadRequest, "Invalid JSON format")
+		return
+	}
+
+	if itemInput.Condition != nil && !validItemConditions[*itemInput.Condition] {
+		helper.ErrorResponse(writer, http.StatusBadRequest, "Invalid condition")
+		return
+	}
+
+	if itemInput.Status != nil && !manualItemStatuses[*itemInput.Status] {
+		helper.ErrorResponse(writer, http.StatusBadRequest, "Status must be one of available, damaged, lost, withdrawn")
+		return
+	}
+
+	tx, err := itemHandler.DB.Beginx()
+	if err != nil {
+		log.Printf("UpdateItem - Transaction start error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to start transaction")
+		return
+	}
+	defer tx.Rollback()
+
+	var current struct {
+		BookID int    `db:"book_id"`
+		Status string `db:"status"`
+	}
+
+	err = tx.Get(&current, `SELECT book_id, status FROM items WHERE id = $1 FOR UPDATE`, itemId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			helper.ErrorResponse(writer, http.StatusNotFound, "Copy not found")
+			return
+		}
+		log.Printf("UpdateItem - Fetch copy error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to fetch copy")
+		return
+	}
+
+	statusChanged := itemInput.Status != nil && *itemInput.Status != current.Status
+	if statusChanged && (current.Status == "on_loan" || current.Status == "on_hold") {
+		helper.ErrorResponse(writer, http.StatusConflict, "Copy is in circulation, return it or cancel the hold first")
+		return
+	}
+
+	_, err = tx.Exec(`
+		UPDATE items
+		SET condition = COALESCE($1, condition),
+		    location = COALESCE($2, location)
+		WHERE id = $3
+	`, itemInput.Condition, itemInput.Location, itemId)
+	if err != nil {
+		log.Printf("UpdateItem - Update error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to update copy")
+		return
+	}
+
+	if statusChanged {
+		if *itemInput.Status == "available" {
+			err = allocateCopy(tx, current.BookID, itemId)
+		} else {
+			_, err = tx.Exec(`UPDATE items SET status = $1 WHERE id = $2`, *itemInput.Status, itemId)
+		}
+		if err != nil {
+			log.Printf("UpdateItem - Update status error: %v", err)
+			helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to update copy status")
+			return
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		log.Printf("UpdateItem - Transaction commit error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to commit transaction")
+		return
+	}
+
+	helper.SuccessResponse(writer, http.StatusOK, map[string]string{
+		"message": "Copy updated successfully",
+	})
+}
+
+// pickLoanItem chooses the copy that userId will take home: the copy held for
+// them if they have a ready hold, the copy with the given barcode, or any copy
+// on the shelf. The chosen row is locked for the rest of the transaction.
+func pickLoanItem(tx *sqlx.Tx, userId int, bookId int, barcode string) (int, error) {
+	var heldItemId *int
+	err := tx.Get(&heldItemId, `
+		SELECT item_id FROM holds
+		WHERE user_id = $1 AND book_id = $2 AND status = 'ready'
+		FOR UPDATE
+	`, userId, bookId)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return 0, err
+	}
+
+	if heldItemId != nil {
+		if barcode != "" {
+			var heldBarcode string
+			err = tx.Get(&heldBarcode, `SELECT barcode FROM items WHERE id = $1`, *heldItemId)
+			if err != nil {
+				return 0, err
+			}
+			if heldBarcode != barcode {
+				return 0, &circulationError{status: http.StatusBadRequest, message: "Copy " + heldBarcode + " is being held for you, borrow that copy instead"}
+			}
+		}
+		return *heldItemId, nil
+	}
+
+	if barcode != "" {
+		var item struct {
+			ID     int    `db:"id"`
+			Status string `db:"status"`
+		}
+
+		err = tx.Get(&item, `
+			SELECT id, status FROM items
+			WHERE barcode = $1 AND book_id = $2
+			FOR UPDATE
+		`, barcode, bookId)
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, &circulationError{status: http.StatusBadRequest, message: "Copy not found for this book"}
+		}
+		if err != nil {
+			return 0, err
+		}
+		if item.Status != "available" {
+			return 0, &circulationError{status: http.StatusBadRequest, message: "Copy is not available"}
+		}
+		return item.ID, nil
+	}
+
+	var itemId int
+	err = tx.Get(&itemId, `
+		SELECT id FROM items
+		WHERE book_id = $1 AND status = 'available'
+		ORDER BY id
+		LIMIT 1
+		FOR UPDATE SKIP LOCKED
+	`, bookId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, &circulationError{status: http.StatusBadRequest, message: "Book is not available, place a hold to join the queue"}
+	}
+	return itemId, err
+}
+
+// checkInItem records the condition of a returned copy. Damaged copies are
+// taken out of circulation, everything else goes to the hold queue or shelf.
+func checkInItem(tx *sqlx.Tx, bookId int, itemId int, condition string) error {
+	if condition != "" {
+		_, err := tx.Exec(`UPDATE items SET condition = $1 WHERE id = $2`, condition, itemId)
+		if err != nil {
+			return err
+		}
+	}
+
+	if condition == "damaged" {
+		_, err := tx.Exec(`UPDATE items SET status = 'damaged' WHERE id = $1`, itemId)
+		return err
+	}
+
+	return allocateCopy(tx, bookId, itemId)
+}
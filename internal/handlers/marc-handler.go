@@ -0,0 +1,567 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/faqq11/lib-management/internal/helper"
+	"github.com/faqq11/lib-management/internal/marc"
+	"github.com/faqq11/lib-management/internal/models"
+	"github.com/faqq11/lib-management/internal/models/response"
+	"github.com/gorilla/mux"
+	"github.com/jmoiron/sqlx"
+)
+
+type MarcHandler struct {
+	DB *sqlx.DB
+}
+
+const (
+	marcFormatBinary = "marc21"
+	marcFormatXML    = "marcxml"
+)
+
+// marcSummaryLength is the most summary text one 520 field can hold. The
+// directory gives field lengths in four digits, and the indicators, subfield
+// code and terminators take 5 of those bytes.
+const marcSummaryLength = 9999 - 5
+
+var marcYearPattern = regexp.MustCompile(`\d{4}`)
+
+// marcPagesPattern finds the page count in a 300 $a extent such as
+// "xii, 320 p." or "320 pages".
+var marcPagesPattern = regexp.MustCompile(`(\d+)\s*(?:p\b|pages)`)
+
+// marcRelatorRoles maps MARC relator terms and codes onto contributor roles.
+var marcRelatorRoles = map[string]string{
+	"author":      contributorRoleAuthor,
+	"aut":         contributorRoleAuthor,
+	"editor":      "editor",
+	"edt":         "editor",
+	"translator":  "translator",
+	"trl":         "translator",
+	"illustrator": "illustrator",
+	"ill":         "illustrator",
+}
+
+var marcRelatorTerms = map[string]string{
+	"editor":      "editor",
+	"translator":  "translator",
+	"illustrator": "illustrator",
+}
+
+func (importHandler *ImportHandler) ImportMARC(writer http.ResponseWriter, request *http.Request) {
+	dryRun, createCategories, err := parseImportOptions(request)
+	if err != nil {
+		helper.ErrorResponse(writer, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	format := request.URL.Query().Get("format")
+	if format != "" && format != marcFormatBinary && format != marcFormatXML {
+		helper.ErrorResponse(writer, http.StatusBadRequest, "format must be marc21 or marcxml")
+		return
+	}
+
+	request.Body = http.MaxBytesReader(writer, request.Body, maxImportUploadBytes)
+	file, _, err := request.FormFile("file")
+	if err != nil {
+		log.Printf("ImportMARC - Form file error: %v", err)
+		helper.ErrorResponse(writer, http.StatusBadRequest, "A MARC file is required in the file field")
+		return
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	if format == "" {
+		format = detectMARCFormat(reader)
+	}
+
+	var records []catalogRecord
+
+	if format == marcFormatXML {
+		marcRecords, err := marc.ReadXML(reader)
+		if err != nil {
+			helper.ErrorResponse(writer, http.StatusBadRequest, "Failed to parse MARCXML: "+err.Error())
+			return
+		}
+		for i, marcRecord := range marcRecords {
+			records = append(records, catalogRecordFromMARC(i+1, marcRecord))
+		}
+	} else {
+		marcReader := marc.NewReader(reader)
+		for row := 1; ; row++ {
+			marcRecord, err := marcReader.Read()
+			if err == io.EOF {
+				break
+			}
+
+			var parseErr *marc.ParseError
+			if errors.As(err, &parseErr) {
+				records = append(records, catalogRecord{Row: row, Errors: []string{parseErr.Error()}})
+				continue
+			}
+			if err != nil {
+				log.Printf("ImportMARC - Read error: %v", err)
+				helper.ErrorResponse(writer, http.StatusBadRequest, "Failed to read the MARC file")
+				return
+			}
+
+			records = append(records, catalogRecordFromMARC(row, marcRecord))
+		}
+	}
+
+	importHandler.importRecords(writer, "ImportMARC", records, dryRun, createCategories)
+}
+
+func (marcHandler *MarcHandler) ExportBook(writer http.ResponseWriter, request *http.Request) {
+	vars := mux.Vars(request)
+	id := vars["id"]
+
+	bookId, err := strconv.Atoi(id)
+	if err != nil {
+		log.Printf("ExportBook - Invalid ID: %s, error: %v", id, err)
+		helper.ErrorResponse(writer, http.StatusBadRequest, "Invalid book ID")
+		return
+	}
+
+	format, ok := parseMARCExportFormat(writer, request)
+	if !ok {
+		return
+	}
+
+	var book response.BookResponse
+	err = marcHandler.DB.Get(&book, bookSelectQuery+` WHERE b.id = $1`, bookId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			helper.ErrorResponse(writer, http.StatusNotFound, "Book not found")
+			return
+		}
+
+		log.Printf("ExportBook - Database error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	contributors, err := bookContributors(marcHandler.DB, bookId)
+	if err != nil {
+		log.Printf("ExportBook - Contributors error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	recordWriter, err := newMARCRecordWriter(writer, format, "book-"+id)
+	if err != nil {
+		log.Printf("ExportBook - Writer error: %v", err)
+		return
+	}
+
+	err = recordWriter.write(marcRecordFromBook(book, contributors))
+	if err == nil {
+		err = recordWriter.close()
+	}
+	if err != nil {
+		log.Printf("ExportBook - Write error: %v", err)
+	}
+}
+
+// bookContributorsColumn is the contributors of book b as a JSON array, in
+// the same order as bookContributors.
+const bookContributorsColumn = `
+		coalesce((
+			SELECT json_agg(json_build_object('author_id', ba.author_id, 'name', a.name, 'role', ba.role)
+				ORDER BY ba.role <> 'author', ba.position)
+			FROM book_authors ba
+			JOIN authors a ON ba.author_id = a.id
+			WHERE ba.book_id = b.id
+		), '[]') AS contributors`
+
+// ExportCatalog streams every book as MARC, one row at a time, so the whole
+// catalog is never held in memory. Contributors come with each row rather than
+// from a query per book.
+func (marcHandler *MarcHandler) ExportCatalog(writer http.ResponseWriter, request *http.Request) {
+	format, ok := parseMARCExportFormat(writer, request)
+	if !ok {
+		return
+	}
+
+	rows, err := marcHandler.DB.Queryx("SELECT " + bookSelectColumns + "," + bookContributorsColumn + bookFromClause + ` ORDER BY b.id`)
+	if err != nil {
+		log.Printf("ExportCatalog - Query error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to export catalog")
+		return
+	}
+	defer rows.Close()
+
+	recordWriter, err := newMARCRecordWriter(writer, format, "catalog")
+	if err != nil {
+		log.Printf("ExportCatalog - Writer error: %v", err)
+		return
+	}
+
+	// The status is already sent, so failures from here on can only be logged.
+	for rows.Next() {
+		var row struct {
+			response.BookResponse
+			ContributorsJSON []byte `db:"contributors"`
+		}
+		err = rows.StructScan(&row)
+		if err != nil {
+			log.Printf("ExportCatalog - Scan error: %v", err)
+			return
+		}
+		book := row.BookResponse
+
+		var contributors []response.ContributorResponse
+		err = json.Unmarshal(row.ContributorsJSON, &contributors)
+		if err != nil {
+			log.Printf("ExportCatalog - Contributors error: %v", err)
+			return
+		}
+
+		err = recordWriter.write(marcRecordFromBook(book, contributors))
+		if errors.Is(err, marc.ErrRecordTooLong) || errors.Is(err, marc.ErrFieldTooLong) {
+			log.Printf("ExportCatalog - Skipped book %d: %v", book.ID, err)
+			continue
+		}
+		if err != nil {
+			log.Printf("ExportCatalog - Write error: %v", err)
+			return
+		}
+	}
+
+	err = rows.Err()
+	if err != nil {
+		log.Printf("ExportCatalog - Rows error: %v", err)
+		return
+	}
+
+	err = recordWriter.close()
+	if err != nil {
+		log.Printf("ExportCatalog - Close error: %v", err)
+	}
+}
+
+// catalogRecordFromMARC maps the bibliographic fields of a MARC record onto a
+// book: 245 title, 100/110/700 author, 020 ISBN, 250 edition, 264/260
+// publisher and publication year, 300 page count, 041/008 language, 082/050
+// call number, 852 room and shelf, 520 summary and the first 650 subject as
+// category.
+func catalogRecordFromMARC(row int, record *marc.Record) catalogRecord {
+	result := catalogRecord{Row: row}
+	book := &result.Book
+
+	book.Title = marc.TrimPunctuation(record.Subfield("245", "a"))
+	if subtitle := marc.TrimPunctuation(record.Subfield("245", "b")); subtitle != "" {
+		book.Title += ": " + subtitle
+	}
+
+	for _, tag := range []string{"100", "110"} {
+		if author := marc.TrimPunctuation(record.Subfield(tag, "a")); author != "" {
+			book.Contributors = append(book.Contributors, models.BookContributor{Name: author, Role: contributorRoleAuthor})
+			break
+		}
+	}
+
+	// 700 added entries are co-authors unless their relator says otherwise.
+	for _, field := range record.DataFields {
+		if field.Tag != "700" {
+			continue
+		}
+
+		contributor := models.BookContributor{Role: contributorRoleAuthor}
+		for _, subfield := range field.Subfields {
+			switch subfield.Code {
+			case "a":
+				contributor.Name = marc.TrimPunctuation(subfield.Value)
+			case "e", "4":
+				if role, ok := marcRelatorRoles[strings.TrimRight(marc.TrimPunctuation(subfield.Value), ".")]; ok {
+					contributor.Role = role
+				}
+			}
+		}
+		if contributor.Name != "" {
+			book.Contributors = append(book.Contributors, contributor)
+		}
+	}
+
+	for _, contributor := range book.Contributors {
+		if contributor.Role == contributorRoleAuthor {
+			book.Author = contributor.Name
+			break
+		}
+	}
+
+	// 020 $a is often followed by a qualifier, e.g. "0306406152 (pbk.)".
+	if isbnFields := strings.Fields(record.Subfield("020", "a")); len(isbnFields) > 0 {
+		book.ISBN13 = &isbnFields[0]
+	}
+
+	// Long summaries are exported as several 520 fields.
+	if summary := strings.TrimSpace(strings.Join(record.Subfields("520", "a"), " ")); summary != "" {
+		book.Description = &summary
+	}
+
+	year := marcYearPattern.FindString(record.Subfield("264", "c"))
+	if year == "" {
+		year = marcYearPattern.FindString(record.Subfield("260", "c"))
+	}
+	if fixed := record.ControlField("008"); year == "" && len(fixed) >= 11 {
+		year = marcYearPattern.FindString(fixed[7:11])
+	}
+	if year != "" {
+		yearInt, _ := strconv.Atoi(year)
+		book.PublicationYear = &yearInt
+	}
+
+	if edition := marc.TrimPunctuation(record.Subfield("250", "a")); edition != "" {
+		book.Edition = &edition
+	}
+
+	publisher := marc.TrimPunctuation(record.Subfield("264", "b"))
+	if publisher == "" {
+		publisher = marc.TrimPunctuation(record.Subfield("260", "b"))
+	}
+	if publisher != "" {
+		book.Publisher = &publisher
+	}
+
+	if pages := marcPagesPattern.FindStringSubmatch(record.Subfield("300", "a")); pages != nil {
+		pageCount, _ := strconv.Atoi(pages[1])
+		book.PageCount = &pageCount
+	}
+
+	language := strings.TrimSpace(record.Subfield("041", "a"))
+	if fixed := record.ControlField("008"); language == "" && len(fixed) >= 38 {
+		language = strings.TrimSpace(fixed[35:38])
+	}
+	if language != "" && language != "und" && language != "|||" {
+		book.Language = &language
+	}
+
+	// 082 and 050 are the call numbers assigned by the cataloguing agency,
+	// which are worth keeping but not worth rejecting the record over.
+	for _, classification := range []struct{ tag, scheme string }{{"082", helper.CallNumberDewey}, {"050", helper.CallNumberLC}} {
+		classNumber := strings.ReplaceAll(record.Subfield(classification.tag, "a"), "/", "")
+		callNumber := strings.TrimSpace(classNumber + " " + record.Subfield(classification.tag, "b"))
+		if callNumber == "" {
+			continue
+		}
+		if _, _, err := helper.CallNumberSortKey(callNumber, classification.scheme); err == nil {
+			scheme := classification.scheme
+			book.CallNumber = &callNumber
+			book.CallNumberScheme = &scheme
+			break
+		}
+	}
+
+	if room := strings.TrimSpace(record.Subfield("852", "b")); room != "" {
+		book.Room = &room
+	}
+	if shelf := strings.TrimSpace(record.Subfield("852", "c")); shelf != "" {
+		book.Shelf = &shelf
+	}
+
+	result.Category = strings.TrimRight(marc.TrimPunctuation(record.Subfield("650", "a")), ".")
+
+	result.validate()
+	return result
+}
+
+func marcRecordFromBook(book response.BookResponse, contributors []response.ContributorResponse) *marc.Record {
+	record := marc.NewRecord()
+	record.AddControlField("001", strconv.Itoa(book.ID))
+
+	// 008 positions 0-5 are the date entered, 6-10 the publication date and
+	// 35-37 the language, which MARC only has three letter codes for.
+	date1 := "    "
+	if book.PublicationYear != nil && *book.PublicationYear >= 1000 && *book.PublicationYear <= 9999 {
+		date1 = strconv.Itoa(*book.PublicationYear)
+	}
+	language := "und"
+	if book.Language != nil && len(*book.Language) == 3 {
+		language = *book.Language
+	}
+	record.AddControlField("008", book.CreatedAt.Format("060102")+"s"+date1+strings.Repeat(" ", 24)+language+" d")
+
+	if book.ISBN13 != nil {
+		record.AddDataField("020", " ", " ", "a", *book.ISBN13)
+	}
+
+	if book.Language != nil {
+		record.AddDataField("041", " ", " ", "a", *book.Language)
+	}
+
+	// The class number goes in $a and the cutters after it in $b.
+	if book.CallNumber != nil {
+		tag := "082"
+		if book.CallNumberScheme != nil && *book.CallNumberScheme == helper.CallNumberLC {
+			tag = "050"
+		}
+		classNumber, item, _ := strings.Cut(*book.CallNumber, " ")
+		callNumber := []string{"a", classNumber}
+		if item != "" {
+			callNumber = append(callNumber, "b", item)
+		}
+		record.AddDataField(tag, " ", "4", callNumber...)
+	}
+
+	// The first author is the main entry, everyone else an added entry.
+	titleIndicator := "0"
+	var addedEntries []response.ContributorResponse
+	for _, contributor := range contributors {
+		if titleIndicator == "0" && contributor.Role == contributorRoleAuthor {
+			record.AddDataField("100", "1", " ", "a", contributor.Name)
+			titleIndicator = "1"
+			continue
+		}
+		addedEntries = append(addedEntries, contributor)
+	}
+	if titleIndicator == "0" && book.Author != "" {
+		record.AddDataField("100", "1", " ", "a", book.Author)
+		titleIndicator = "1"
+	}
+
+	record.AddDataField("245", titleIndicator, "0", "a", book.Title)
+
+	if book.Edition != nil {
+		record.AddDataField("250", " ", " ", "a", *book.Edition)
+	}
+
+	var publication []string
+	if book.Publisher != nil {
+		publication = append(publication, "b", *book.Publisher)
+	}
+	if book.PublicationYear != nil {
+		publication = append(publication, "c", strconv.Itoa(*book.PublicationYear))
+	}
+	if len(publication) > 0 {
+		record.AddDataField("264", " ", "1", publication...)
+	}
+
+	if book.PageCount != nil {
+		record.AddDataField("300", " ", " ", "a", strconv.Itoa(*book.PageCount)+" p.")
+	}
+
+	if book.Description != nil {
+		for _, summary := range splitMARCSummary(*book.Description) {
+			record.AddDataField("520", " ", " ", "a", summary)
+		}
+	}
+
+	if book.Category != nil {
+		record.AddDataField("650", " ", "4", "a", *book.Category)
+	}
+
+	for _, contributor := range addedEntries {
+		record.AddDataField("700", "1", " ", "a", contributor.Name, "e", marcRelatorTerms[contributor.Role])
+	}
+
+	// 852 $h is the call number as written on the spine.
+	var location []string
+	if book.Room != nil {
+		location = append(location, "b", *book.Room)
+	}
+	if book.Shelf != nil {
+		location = append(location, "c", *book.Shelf)
+	}
+	if book.CallNumber != nil {
+		location = append(location, "h", *book.CallNumber)
+	}
+	if len(location) > 0 {
+		record.AddDataField("852", " ", " ", location...)
+	}
+
+	return record
+}
+
+// splitMARCSummary splits a summary into parts that each fit a 520 field,
+// breaking at the last space before the limit where there is one.
+func splitMARCSummary(summary string) []string {
+	var parts []string
+	for len(summary) > marcSummaryLength {
+		end := marcSummaryLength
+		for !utf8.RuneStart(summary[end]) {
+			end--
+		}
+		if space := strings.LastIndexByte(summary[:end], ' '); space > 0 {
+			parts = append(parts, summary[:space])
+			summary = summary[space+1:]
+			continue
+		}
+		parts = append(parts, summary[:end])
+		summary = summary[end:]
+	}
+	return append(parts, summary)
+}
+
+func detectMARCFormat(reader *bufio.Reader) string {
+	peeked, _ := reader.Peek(512)
+	if bytes.HasPrefix(bytes.TrimLeft(peeked, " \t\r\n\ufeff"), []byte("<")) {
+		return marcFormatXML
+	}
+	return marcFormatBinary
+}
+
+func parseMARCExportFormat(writer http.ResponseWriter, request *http.Request) (string, bool) {
+	format := request.URL.Query().Get("format")
+	switch format {
+	case "":
+		return marcFormatBinary, true
+	case marcFormatBinary, marcFormatXML:
+		return format, true
+	}
+
+	helper.ErrorResponse(writer, http.StatusBadRequest, "format must be marc21 or marcxml")
+	return "", false
+}
+
+// marcRecordWriter writes MARC records to a response in either format.
+type marcRecordWriter struct {
+	xmlWriter *marc.XMLWriter
+	writer    io.Writer
+}
+
+func newMARCRecordWriter(writer http.ResponseWriter, format string, filename string) (*marcRecordWriter, error) {
+	if format == marcFormatXML {
+		writer.Header().Set("Content-Type", "application/marcxml+xml")
+		writer.Header().Set("Content-Disposition", `attachment; filename="`+filename+`.xml"`)
+		writer.WriteHeader(http.StatusOK)
+
+		xmlWriter, err := marc.NewXMLWriter(writer)
+		if err != nil {
+			return nil, err
+		}
+		return &marcRecordWriter{xmlWriter: xmlWriter}, nil
+	}
+
+	writer.Header().Set("Content-Type", "application/marc")
+	writer.Header().Set("Content-Disposition", `attachment; filename="`+filename+`.mrc"`)
+	writer.WriteHeader(http.StatusOK)
+
+	return &marcRecordWriter{writer: writer}, nil
+}
+
+func (recordWriter *marcRecordWriter) write(record *marc.Record) error {
+	if recordWriter.xmlWriter != nil {
+		return recordWriter.xmlWriter.Write(record)
+	}
+	return marc.WriteISO2709(recordWriter.writer, record)
+}
+
+func (recordWriter *marcRecordWriter) close() error {
+	if recordWriter.xmlWriter != nil {
+		return recordWriter.xmlWriter.Close()
+	}
+	return nil
+}
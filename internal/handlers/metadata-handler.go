@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/faqq11/lib-management/internal/helper"
+	"github.com/faqq11/lib-management/internal/metadata"
+	"github.com/faqq11/lib-management/internal/models/response"
+	"github.com/gorilla/mux"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+type MetadataHandler struct {
+	DB       *sqlx.DB
+	Provider metadata.Provider
+}
+
+// LookupISBN pre-fills a new book from the metadata provider. The draft can
+// be reviewed and sent to POST /api/create-book as is.
+func (metadataHandler *MetadataHandler) LookupISBN(writer http.ResponseWriter, request *http.Request) {
+	if metadataHandler.Provider == nil {
+		helper.ErrorResponse(writer, http.StatusServiceUnavailable, "Metadata lookup is not configured")
+		return
+	}
+
+	vars := mux.Vars(request)
+
+	isbn13, err := helper.NormalizeISBN(vars["isbn"])
+	if err != nil {
+		helper.ErrorResponse(writer, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	record, err := metadataHandler.Provider.Lookup(request.Context(), isbn13)
+	if err != nil {
+		if errors.Is(err, metadata.ErrNotFound) {
+			helper.ErrorResponse(writer, http.StatusNotFound, "No metadata found for this ISBN")
+			return
+		}
+
+		log.Printf("LookupISBN - Provider error: %v", err)
+		helper.ErrorResponse(writer, http.StatusBadGateway, "Metadata provider failed")
+		return
+	}
+
+	draft := response.CatalogDraft{
+		Title:           record.Title,
+		Author:          strings.Join(record.Authors, ", "),
+		ISBN13:          isbn13,
+		PublicationYear: record.Year,
+		Publisher:       record.Publisher,
+		Subjects:        record.Subjects,
+		CoverURL:        record.CoverURL,
+	}
+
+	if isbn10, ok := helper.ISBN13To10(isbn13); ok {
+		draft.ISBN10 = &isbn10
+	}
+
+	if draft.Subjects == nil {
+		draft.Subjects = []string{}
+	}
+
+	// Suggest the first existing category named after one of the subjects.
+	if len(record.Subjects) > 0 {
+		subjects := make([]string, len(record.Subjects))
+		for i, subject := range record.Subjects {
+			subjects[i] = strings.ToLower(subject)
+		}
+
+		var categoryId int
+		err = metadataHandler.DB.Get(&categoryId, `
+			SELECT id FROM categories
+			WHERE lower(name) = ANY($1)
+			ORDER BY array_position($1, lower(name))
+			LIMIT 1
+		`, pq.Array(subjects))
+		if err == nil {
+			draft.CategoryID = &categoryId
+		} else if !errors.Is(err, sql.ErrNoRows) {
+			log.Printf("LookupISBN - Category match error: %v", err)
+			helper.ErrorResponse(writer, http.StatusInternalServerError, "Internal server error")
+			return
+		}
+	}
+
+	var existingBookId int
+	err = metadataHandler.DB.Get(&existingBookId, `SELECT id FROM books WHERE isbn13 = $1`, isbn13)
+	if err == nil {
+		draft.ExistingBookID = &existingBookId
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		log.Printf("LookupISBN - Existing book error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	helper.SuccessResponse(writer, http.StatusOK, draft)
+}
@@ -0,0 +1,314 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/faqq11/lib-management/internal/helper"
+	"github.com/faqq11/lib-management/internal/models"
+	"github.com/faqq11/lib-management/internal/models/response"
+	"github.com/gorilla/mux"
+	"github.com/jmoiron/sqlx"
+)
+
+type PolicyHandler struct {
+	DB *sqlx.DB
+}
+
+const (
+	ruleMaxLoans            = "max_loans"
+	ruleMaxCategoryLoans    = "max_category_loans"
+	ruleMaxOutstandingFines = "max_outstanding_fines"
+)
+
+type policyInput struct {
+	Rule       string  `json:"rule"`
+	Role       *string `json:"role"`
+	CategoryID *int    `json:"category_id"`
+	MaxValue   *int    `json:"max_value"`
+}
+
+func (input policyInput) validate() string {
+	switch input.Rule {
+	case ruleMaxLoans, ruleMaxOutstandingFines:
+		if input.CategoryID != nil {
+			return "category_id is only allowed for " + ruleMaxCategoryLoans
+		}
+	case ruleMaxCategoryLoans:
+	default:
+		return "Rule must be one of " + ruleMaxLoans + ", " + ruleMaxCategoryLoans + ", " + ruleMaxOutstandingFines
+	}
+
+	if input.MaxValue == nil || *input.MaxValue < 0 {
+		return "max_value is required and cannot be negative"
+	}
+
+	return ""
+}
+
+func (policyHandler *PolicyHandler) GetPolicies(writer http.ResponseWriter, request *http.Request) {
+	var policies []models.CirculationPolicy
+
+	err := policyHandler.DB.Select(&policies, `
+		SELECT id, rule, role, category_id, max_value, created_at
+		FROM circulation_policies
+		ORDER BY id
+	`)
+	if err != nil {
+		log.Printf("GetPolicies - Select error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to fetch policies")
+		return
+	}
+
+	helper.SuccessResponse(writer, http.StatusOK, policies)
+}
+
+func (policyHandler *PolicyHandler) CreatePolicy(writer http.ResponseWriter, request *http.Request) {
+	var input policyInput
+
+	err := json.NewDecoder(request.Body).Decode(&input)
+	if err != nil {
+		log.Printf("CreatePolicy - JSON decode error: %v", err)
+		helper.ErrorResponse(writer, http.StatusBadRequest, "Invalid JSON format")
+		return
+	}
+
+	if message := input.validate(); message != "" {
+		helper.ErrorResponse(writer, http.StatusBadRequest, message)
+		return
+	}
+
+	var policyId int
+	err = policyHandler.DB.Get(&policyId, `
+		INSERT INTO circulation_policies (rule, role, category_id, max_value)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id
+	`, input.Rule, input.Role, input.CategoryID, *input.MaxValue)
+	if err != nil {
+		log.Printf("CreatePolicy - Insert error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to create policy")
+		return
+	}
+
+	helper.SuccessResponse(writer, http.StatusCreated, map[string]interface{}{
+		"message": "Policy created successfully",
+		"id":      policyId,
+	})
+}
+
+func (policyHandler *PolicyHandler) UpdatePolicy(writer http.ResponseWriter, request *http.Request) {
+	vars := mux.Vars(request)
+	id := vars["id"]
+
+	policyId, err := strconv.Atoi(id)
+	if err != nil {
+		log.Printf("UpdatePolicy - Invalid ID: %s, error: %v", id, err)
+		helper.ErrorResponse(writer, http.StatusBadRequest, "Invalid policy ID")
+		return
+	}
+
+	var input policyInput
+
+	err = json.NewDecoder(request.Body).Decode(&input)
+	if err != nil {
+		log.Printf("UpdatePolicy - JSON decode error: %v", err)
+		helper.ErrorResponse(writer, http.StatusBadRequest, "Invalid JSON format")
+		return
+	}
+
+	if message := input.validate(); message != "" {
+		helper.ErrorResponse(writer, http.StatusBadRequest, message)
+		return
+	}
+
+	result, err := policyHandler.DB.Exec(`
+		UPDATE circulation_policies
+		SET rule = $1,
+		    role = $2,
+		    category_id = $3,
+		    max_value = $4
+		WHERE id = $5
+	`, input.Rule, input.Role, input.CategoryID, *input.MaxValue, policyId)
+	if err != nil {
+		log.Printf("UpdatePolicy - Update error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to update policy")
+		return
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		log.Printf("UpdatePolicy - RowsAffected error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to check update result")
+		return
+	}
+
+	if rowsAffected == 0 {
+		helper.ErrorResponse(writer, http.StatusNotFound, "Policy not found")
+		return
+	}
+
+	helper.SuccessResponse(writer, http.StatusOK, map[string]string{
+		"message": "Policy updated successfully",
+	})
+}
+
+func (policyHandler *PolicyHandler) DeletePolicy(writer http.ResponseWriter, request *http.Request) {
+	vars := mux.Vars(request)
+	id := vars["id"]
+
+	policyId, err := strconv.Atoi(id)
+	if err != nil {
+		log.Printf("DeletePolicy - Invalid ID: %s, error: %v", id, err)
+		helper.ErrorResponse(writer, http.StatusBadRequest, "Invalid policy ID")
+		return
+	}
+
+	result, err := policyHandler.DB.Exec(`DELETE FROM circulation_policies WHERE id = $1`, policyId)
+	if err != nil {
+		log.Printf("DeletePolicy - Delete error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to delete policy")
+		return
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		helper.ErrorResponse(writer, http.StatusNotFound, "Policy not found")
+		return
+	}
+
+	helper.SuccessResponse(writer, http.StatusOK, map[string]string{
+		"message": "Policy deleted successfully",
+	})
+}
+
+// evaluateCirculationPolicies checks whether userId may take bookId on loan.
+// Every policy matching the user's role (or with no role) is enforced, so the
+// strictest one wins. A nil refusal means the loan is allowed.
+func evaluateCirculationPolicies(tx *sqlx.Tx, userId int, role string, bookId int) (*response.PolicyRefusal, error) {
+	var userData struct {
+		Blocked       bool    `db:"blocked"`
+		BlockedReason *string `db:"blocked_reason"`
+	}
+
+	err := tx.Get(&userData, `SELECT blocked, blocked_reason FROM users WHERE id = $1`, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	if userData.Blocked {
+		message := "Your account is blocked from borrowing"
+		if userData.BlockedReason != nil && *userData.BlockedReason != "" {
+			message += ": " + *userData.BlockedReason
+		}
+		return &response.PolicyRefusal{Rule: "blocked", Message: message}, nil
+	}
+
+	var policies []models.CirculationPolicy
+	err = tx.Select(&policies, `
+		SELECT id, rule, role, category_id, max_value, created_at
+		FROM circulation_policies
+		WHERE role IS NULL OR role = $1
+		ORDER BY id
+	`, role)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(policies) == 0 {
+		return nil, nil
+	}
+
+	var bookCategoryId *int
+	err = tx.Get(&bookCategoryId, `SELECT category_id FROM books WHERE id = $1`, bookId)
+	if err != nil {
+		return nil, err
+	}
+
+	var activeLoans int
+	err = tx.Get(&activeLoans, `
+		SELECT COUNT(*) FROM borrowings
+		WHERE user_id = $1 AND returned_at IS NULL AND lost_at IS NULL
+	`, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	var categoryLoans int
+	if bookCategoryId != nil {
+		err = tx.Get(&categoryLoans, `
+			SELECT COUNT(*) FROM borrowings br
+			JOIN books b ON br.book_id = b.id
+			WHERE br.user_id = $1 AND br.returned_at IS NULL AND br.lost_at IS NULL
+			  AND b.category_id = $2
+		`, userId, *bookCategoryId)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	balance := -1
+
+	for _, policy := range policies {
+		policyId := policy.ID
+		limit := policy.MaxValue
+
+		switch policy.Rule {
+		case ruleMaxLoans:
+			if activeLoans >= limit {
+				current := activeLoans
+				return &response.PolicyRefusal{
+					Rule:     policy.Rule,
+					Message:  "You have reached the maximum number of borrowed books",
+					PolicyID: &policyId,
+					Limit:    &limit,
+					Current:  &current,
+				}, nil
+			}
+
+		case ruleMaxCategoryLoans:
+			if bookCategoryId == nil {
+				continue
+			}
+			if policy.CategoryID != nil && *policy.CategoryID != *bookCategoryId {
+				continue
+			}
+			if categoryLoans >= limit {
+				current := categoryLoans
+				return &response.PolicyRefusal{
+					Rule:     policy.Rule,
+					Message:  "You have reached the maximum number of borrowed books in this category",
+					PolicyID: &policyId,
+					Limit:    &limit,
+					Current:  &current,
+				}, nil
+			}
+
+		case ruleMaxOutstandingFines:
+			if balance < 0 {
+				err = accrueOverdueFines(tx, userId)
+				if err != nil {
+					return nil, err
+				}
+
+				balance, err = outstandingBalance(tx, userId)
+				if err != nil {
+					return nil, err
+				}
+			}
+			if balance > limit {
+				current := balance
+				return &response.PolicyRefusal{
+					Rule:     policy.Rule,
+					Message:  "Outstanding fines exceed the allowed limit, please pay your fines first",
+					PolicyID: &policyId,
+					Limit:    &limit,
+					Current:  &current,
+				}, nil
+			}
+		}
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,259 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/faqq11/lib-management/internal/helper"
+	"github.com/faqq11/lib-management/internal/models/response"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+type TagHandler struct {
+	DB *sqlx.DB
+}
+
+const maxTagLength = 50
+
+type bookTagsInput struct {
+	BookIDs []int64  `json:"book_ids"`
+	Tags    []string `json:"tags"`
+}
+
+// TagBooks adds every tag to every book, creating tags that do not exist yet.
+// Tags a book already has are left alone.
+func (tagHandler *TagHandler) TagBooks(writer http.ResponseWriter, request *http.Request) {
+	input, ok := decodeBookTagsInput(writer, request, "TagBooks")
+	if !ok {
+		return
+	}
+
+	tx, err := tagHandler.DB.Beginx()
+	if err != nil {
+		log.Printf("TagBooks - Transaction start error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to start transaction")
+		return
+	}
+	defer tx.Rollback()
+
+	if !checkTaggedBooksExist(writer, tx, "TagBooks", input.BookIDs) {
+		return
+	}
+
+	tagIds := make([]int64, 0, len(input.Tags))
+	for _, name := range input.Tags {
+		var tagId int64
+		err = tx.Get(&tagId, `
+			INSERT INTO tags (name)
+			VALUES ($1)
+			ON CONFLICT ((lower(name))) DO UPDATE SET name = tags.name
+			RETURNING id
+		`, name)
+		if err != nil {
+			log.Printf("TagBooks - Insert tag error: %v", err)
+			helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to tag books")
+			return
+		}
+		tagIds = append(tagIds, tagId)
+	}
+
+	result, err := tx.Exec(`
+		INSERT INTO book_tags (book_id, tag_id)
+		SELECT requested_books.id, requested_tags.id
+		FROM unnest($1::int[]) AS requested_books(id)
+		CROSS JOIN unnest($2::int[]) AS requested_tags(id)
+		ON CONFLICT DO NOTHING
+	`, pq.Array(input.BookIDs), pq.Array(tagIds))
+	if err != nil {
+		log.Printf("TagBooks - Insert book tags error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to tag books")
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		log.Printf("TagBooks - Transaction commit error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to commit transaction")
+		return
+	}
+
+	added, _ := result.RowsAffected()
+	helper.SuccessResponse(writer, http.StatusOK, map[string]interface{}{
+		"message": "Books tagged successfully",
+		"added":   added,
+	})
+}
+
+// UntagBooks removes the tags from the books. Tags no book uses any more are
+// deleted.
+func (tagHandler *TagHandler) UntagBooks(writer http.ResponseWriter, request *http.Request) {
+	input, ok := decodeBookTagsInput(writer, request, "UntagBooks")
+	if !ok {
+		return
+	}
+
+	tx, err := tagHandler.DB.Beginx()
+	if err != nil {
+		log.Printf("UntagBooks - Transaction start error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to start transaction")
+		return
+	}
+	defer tx.Rollback()
+
+	if !checkTaggedBooksExist(writer, tx, "UntagBooks", input.BookIDs) {
+		return
+	}
+
+	result, err := tx.Exec(`
+		DELETE FROM book_tags bt
+		USING tags t
+		WHERE bt.tag_id = t.id
+		  AND bt.book_id = ANY($1)
+		  AND lower(t.name) IN (SELECT lower(requested.name) FROM unnest($2::text[]) AS requested(name))
+	`, pq.Array(input.BookIDs), pq.Array(input.Tags))
+	if err != nil {
+		log.Printf("UntagBooks - Delete book tags error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to untag books")
+		return
+	}
+
+	_, err = tx.Exec(`
+		DELETE FROM tags t
+		WHERE lower(t.name) IN (SELECT lower(requested.name) FROM unnest($1::text[]) AS requested(name))
+		  AND NOT EXISTS (SELECT 1 FROM book_tags bt WHERE bt.tag_id = t.id)
+	`, pq.Array(input.Tags))
+	if err != nil {
+		log.Printf("UntagBooks - Delete unused tags error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to untag books")
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		log.Printf("UntagBooks - Transaction commit error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to commit transaction")
+		return
+	}
+
+	removed, _ := result.RowsAffected()
+	helper.SuccessResponse(writer, http.StatusOK, map[string]interface{}{
+		"message": "Books untagged successfully",
+		"removed": removed,
+	})
+}
+
+// GetTagCloud lists the tags in use with how many books have each, most used
+// first.
+func (tagHandler *TagHandler) GetTagCloud(writer http.ResponseWriter, request *http.Request) {
+	limit := 100
+	if limitParam := request.URL.Query().Get("limit"); limitParam != "" {
+		parsed, err := strconv.Atoi(limitParam)
+		if err != nil || parsed < 1 {
+			helper.ErrorResponse(writer, http.StatusBadRequest, "Limit must be a positive integer")
+			return
+		}
+		limit = min(parsed, 500)
+	}
+
+	tags := []response.TagCount{}
+	err := tagHandler.DB.Select(&tags, `
+		SELECT t.id, t.name, COUNT(*) AS count
+		FROM tags t
+		JOIN book_tags bt ON bt.tag_id = t.id
+		GROUP BY t.id, t.name
+		ORDER BY count DESC, t.name
+		LIMIT $1
+	`, limit)
+	if err != nil {
+		log.Printf("GetTagCloud - Select error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to fetch tags")
+		return
+	}
+
+	helper.SuccessResponse(writer, http.StatusOK, tags)
+}
+
+// decodeBookTagsInput reads the body of the bulk endpoints, trimming the tag
+// names and dropping duplicates.
+func decodeBookTagsInput(writer http.ResponseWriter, request *http.Request, logPrefix string) (bookTagsInput, bool) {
+	var input bookTagsInput
+
+	err := json.NewDecoder(request.Body).Decode(&input)
+	if err != nil {
+		log.Printf("%s - JSON decode error: %v", logPrefix, err)
+		helper.ErrorResponse(writer, http.StatusBadRequest, "Invalid JSON format")
+		return input, false
+	}
+
+	if len(input.BookIDs) == 0 {
+		helper.ErrorResponse(writer, http.StatusBadRequest, "book_ids must not be empty")
+		return input, false
+	}
+
+	seen := make(map[string]bool)
+	var tags []string
+	for _, name := range input.Tags {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if len([]rune(name)) > maxTagLength {
+			helper.ErrorResponse(writer, http.StatusBadRequest, "Tags can be at most "+strconv.Itoa(maxTagLength)+" characters")
+			return input, false
+		}
+		if seen[strings.ToLower(name)] {
+			continue
+		}
+		seen[strings.ToLower(name)] = true
+		tags = append(tags, name)
+	}
+
+	if len(tags) == 0 {
+		helper.ErrorResponse(writer, http.StatusBadRequest, "tags must not be empty")
+		return input, false
+	}
+
+	input.Tags = tags
+	return input, true
+}
+
+// checkTaggedBooksExist answers 400 with the ids that are not books, so a
+// typo does not tag half of the selection.
+func checkTaggedBooksExist(writer http.ResponseWriter, queryer sqlx.Queryer, logPrefix string, bookIds []int64) bool {
+	missing := []int64{}
+	err := sqlx.Select(queryer, &missing, `
+		SELECT DISTINCT requested.id
+		FROM unnest($1::int[]) AS requested(id)
+		WHERE NOT EXISTS (SELECT 1 FROM books b WHERE b.id = requested.id)
+		ORDER BY requested.id
+	`, pq.Array(bookIds))
+	if err != nil {
+		log.Printf("%s - Book check error: %v", logPrefix, err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Internal server error")
+		return false
+	}
+
+	if len(missing) > 0 {
+		helper.ErrorResponseWithReason(writer, http.StatusBadRequest, "Some books do not exist", map[string][]int64{"missing_book_ids": missing})
+		return false
+	}
+
+	return true
+}
+
+// bookTags returns the tag names of a book in alphabetical order.
+func bookTags(queryer sqlx.Queryer, bookId int) ([]string, error) {
+	tags := []string{}
+	err := sqlx.Select(queryer, &tags, `
+		SELECT t.name
+		FROM book_tags bt
+		JOIN tags t ON bt.tag_id = t.id
+		WHERE bt.book_id = $1
+		ORDER BY lower(t.name)
+	`, bookId)
+	return tags, err
+}
@@ -4,9 +4,11 @@ import (
 	"encoding/json"
 	"log"
 	"net/http"
+	"strconv"
 
 	"github.com/faqq11/lib-management/internal/helper"
 	"github.com/faqq11/lib-management/internal/models"
+	"github.com/gorilla/mux"
 	"github.com/jmoiron/sqlx"
 )
 
@@ -109,3 +111,78 @@ func (userHandler *UserHandler) Login(writer http.ResponseWriter, request *http.
 		"access_token": token,
 	})
 }
+
+func (userHandler *UserHandler) BlockUser(writer http.ResponseWriter, request *http.Request) {
+	vars := mux.Vars(request)
+	id := vars["id"]
+
+	userId, err := strconv.Atoi(id)
+	if err != nil {
+		log.Printf("BlockUser - Invalid user ID: %s, error: %v", id, err)
+		helper.ErrorResponse(writer, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	var blockInput struct {
+		Reason *string `json:"reason"`
+	}
+
+	if request.ContentLength != 0 {
+		err = json.NewDecoder(request.Body).Decode(&blockInput)
+		if err != nil {
+			log.Printf("BlockUser - JSON decode error: %v", err)
+			helper.ErrorResponse(writer, http.StatusBadRequest, "Invalid JSON format")
+			return
+		}
+	}
+
+	result, err := userHandler.DB.Exec(`
+		UPDATE users SET blocked = true, blocked_reason = $1 WHERE id = $2
+	`, blockInput.Reason, userId)
+	if err != nil {
+		log.Printf("BlockUser - Update error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to block user")
+		return
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		helper.ErrorResponse(writer, http.StatusNotFound, "User not found")
+		return
+	}
+
+	helper.SuccessResponse(writer, http.StatusOK, map[string]interface{}{
+		"message": "User blocked successfully",
+	})
+}
+
+func (userHandler *UserHandler) UnblockUser(writer http.ResponseWriter, request *http.Request) {
+	vars := mux.Vars(request)
+	id := vars["id"]
+
+	userId, err := strconv.Atoi(id)
+	if err != nil {
+		log.Printf("UnblockUser - Invalid user ID: %s, error: %v", id, err)
+		helper.ErrorResponse(writer, http.StatusBadRequest, "Invalid user ID")
+		return
+	}
+
+	result, err := userHandler.DB.Exec(`
+		UPDATE users SET blocked = false, blocked_reason = NULL WHERE id = $1
+	`, userId)
+	if err != nil {
+		log.Printf("UnblockUser - Update error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to unblock user")
+		return
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		helper.ErrorResponse(writer, http.StatusNotFound, "User not found")
+		return
+	}
+
+	helper.SuccessResponse(writer, http.StatusOK, map[string]interface{}{
+		"message": "User unblocked successfully",
+	})
+}
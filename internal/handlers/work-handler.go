@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/faqq11/lib-management/internal/helper"
+	"github.com/faqq11/lib-management/internal/models"
+	"github.com/faqq11/lib-management/internal/models/response"
+	"github.com/gorilla/mux"
+	"github.com/jmoiron/sqlx"
+)
+
+type WorkHandler struct {
+	DB *sqlx.DB
+}
+
+var errWorkNotFound = errors.New("Work not found")
+
+type workInput struct {
+	Title string `json:"title"`
+}
+
+func (workHandler *WorkHandler) GetWorkById(writer http.ResponseWriter, request *http.Request) {
+	workId, ok := parseWorkId(writer, request, "GetWorkById")
+	if !ok {
+		return
+	}
+
+	var work response.WorkResponse
+	err := workHandler.DB.Get(&work, `SELECT id, title, created_at FROM works WHERE id = $1`, workId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			helper.ErrorResponse(writer, http.StatusNotFound, "Work not found")
+			return
+		}
+
+		log.Printf("GetWorkById - Database error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	work.Editions, err = workEditions(workHandler.DB, workId, 0)
+	if err != nil {
+		log.Printf("GetWorkById - Editions error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to fetch editions")
+		return
+	}
+
+	helper.SuccessResponse(writer, http.StatusOK, work)
+}
+
+// GetBookEditions lists the other editions of the work the book belongs to.
+func (workHandler *WorkHandler) GetBookEditions(writer http.ResponseWriter, request *http.Request) {
+	vars := mux.Vars(request)
+	id := vars["id"]
+
+	bookId, err := strconv.Atoi(id)
+	if err != nil {
+		log.Printf("GetBookEditions - Invalid ID: %s, error: %v", id, err)
+		helper.ErrorResponse(writer, http.StatusBadRequest, "Invalid book ID")
+		return
+	}
+
+	var workId *int
+	err = workHandler.DB.Get(&workId, `SELECT work_id FROM books WHERE id = $1`, bookId)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			helper.ErrorResponse(writer, http.StatusNotFound, "Book not found")
+			return
+		}
+
+		log.Printf("GetBookEditions - Database error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	editions := []response.BookResponse{}
+	if workId != nil {
+		editions, err = workEditions(workHandler.DB, *workId, bookId)
+		if err != nil {
+			log.Printf("GetBookEditions - Editions error: %v", err)
+			helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to fetch editions")
+			return
+		}
+	}
+
+	helper.SuccessResponse(writer, http.StatusOK, editions)
+}
+
+func (workHandler *WorkHandler) UpdateWork(writer http.ResponseWriter, request *http.Request) {
+	workId, ok := parseWorkId(writer, request, "UpdateWork")
+	if !ok {
+		return
+	}
+
+	var input workInput
+
+	err := json.NewDecoder(request.Body).Decode(&input)
+	if err != nil {
+		log.Printf("UpdateWork - JSON decode error: %v", err)
+		helper.ErrorResponse(writer, http.StatusBadRequest, "Invalid JSON format")
+		return
+	}
+
+	input.Title = strings.TrimSpace(input.Title)
+	if input.Title == "" {
+		helper.ErrorResponse(writer, http.StatusBadRequest, "Title is required")
+		return
+	}
+
+	result, err := workHandler.DB.Exec(`UPDATE works SET title = $1 WHERE id = $2`, input.Title, workId)
+	if err != nil {
+		log.Printf("UpdateWork - Update error: %v", err)
+		helper.ErrorResponse(writer, http.StatusInternalServerError, "Failed to update work")
+		return
+	}
+
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		helper.ErrorResponse(writer, http.StatusNotFound, "Work not found")
+		return
+	}
+
+	helper.SuccessResponse(writer, http.StatusOK, map[string]string{
+		"message": "Work updated successfully",
+	})
+}
+
+func parseWorkId(writer http.ResponseWriter, request *http.Request, logPrefix string) (int, bool) {
+	id := mux.Vars(request)["id"]
+
+	workId, err := strconv.Atoi(id)
+	if err != nil {
+		log.Printf("%s - Invalid ID: %s, error: %v", logPrefix, id, err)
+		helper.ErrorResponse(writer, http.StatusBadRequest, "Invalid work ID")
+		return 0, false
+	}
+
+	return workId, true
+}
+
+// workEditions returns the books of a work, oldest edition first, leaving
+// out excludeBookId.
+func workEditions(queryer sqlx.Queryer, workId int, excludeBookId int) ([]response.BookResponse, error) {
+	editions := []response.BookResponse{}
+	err := sqlx.Select(queryer, &editions, bookSelectQuery+`
+		WHERE b.work_id = $1 AND b.id <> $2
+		ORDER BY b.publication_year NULLS LAST, b.id
+	`, workId, excludeBookId)
+	return editions, err
+}
+
+// resolveBookWork returns the work a new edition belongs to: the one it names,
+// else the work of a book with the same title and author, else a new work.
+func resolveBookWork(tx *sqlx.Tx, book models.Book) (int, error) {
+	var workId int
+	if book.WorkID != nil {
+		err := tx.Get(&workId, `SELECT id FROM works WHERE id = $1`, *book.WorkID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, errWorkNotFound
+		}
+		return workId, err
+	}
+
+	err := tx.Get(&workId, `
+		SELECT work_id FROM books
+		WHERE lower(title) = lower($1)
+		  AND lower(coalesce(author, '')) = lower($2)
+		  AND work_id IS NOT NULL
+		ORDER BY id
+		LIMIT 1
+	`, book.Title, book.Author)
+	if err == nil {
+		return workId, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return 0, err
+	}
+
+	err = tx.Get(&workId, `INSERT INTO works (title) VALUES ($1) RETURNING id`, book.Title)
+	return workId, err
+}
@@ -0,0 +1,73 @@
+package helper
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	CallNumberDewey = "dewey"
+	CallNumberLC    = "lc"
+)
+
+var ErrInvalidCallNumber = errors.New("call_number must be a Dewey (e.g. 823.914 ROW) or Library of Congress (e.g. QA76.73 .J38 2001) call number")
+
+var ErrInvalidCallNumberScheme = errors.New("call_number_scheme must be dewey or lc")
+
+var (
+	deweyPattern = regexp.MustCompile(`^(\d{1,3})(?:\.(\d+))?(?:\s+(.*))?$`)
+	lcPattern    = regexp.MustCompile(`^([A-Z]{1,3})\s*(\d{1,4})(?:\.(\d+))?(.*)$`)
+)
+
+// CallNumberSortKey validates a call number and returns its scheme and a key
+// that sorts in shelf order when compared byte by byte (COLLATE "C"). An
+// empty scheme is detected from the call number: Dewey starts with a digit,
+// LC with a letter.
+//
+// Class numbers are padded so 5 files before 10, decimals and cutters are
+// compared digit by digit as they are on the shelf, so 823.9 files before
+// 823.914 and .R6 before .R69.
+func CallNumberSortKey(callNumber string, scheme string) (string, string, error) {
+	callNumber = strings.ToUpper(strings.Join(strings.Fields(callNumber), " "))
+	if callNumber == "" {
+		return "", "", ErrInvalidCallNumber
+	}
+
+	if scheme == "" {
+		scheme = CallNumberLC
+		if callNumber[0] >= '0' && callNumber[0] <= '9' {
+			scheme = CallNumberDewey
+		}
+	}
+
+	switch scheme {
+	case CallNumberDewey:
+		match := deweyPattern.FindStringSubmatch(callNumber)
+		if match == nil {
+			return "", "", ErrInvalidCallNumber
+		}
+		class, _ := strconv.Atoi(match[1])
+		return scheme, fmt.Sprintf("%03d.%s %s", class, match[2], callNumberCutters(match[3])), nil
+
+	case CallNumberLC:
+		// The class number is at most four digits, so a digit right after
+		// it means it was longer.
+		match := lcPattern.FindStringSubmatch(callNumber)
+		if match == nil || (match[4] != "" && match[4][0] >= '0' && match[4][0] <= '9') {
+			return "", "", ErrInvalidCallNumber
+		}
+		class, _ := strconv.Atoi(match[2])
+		return scheme, fmt.Sprintf("%-3s%04d.%s %s", match[1], class, match[3], callNumberCutters(match[4])), nil
+	}
+
+	return "", "", ErrInvalidCallNumberScheme
+}
+
+// callNumberCutters turns the cutters and dates after the class number into
+// space separated parts, e.g. ".J38 2001" into "J38 2001".
+func callNumberCutters(rest string) string {
+	return strings.Join(strings.Fields(strings.ReplaceAll(rest, ".", " ")), " ")
+}
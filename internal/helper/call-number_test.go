@@ -0,0 +1,120 @@
+package helper
+
+import (
+	"errors"
+	"testing"
+)
+
+// Each list is in shelf order, so every sort key must be greater than the one
+// before it.
+func TestCallNumberSortKeyShelfOrder(t *testing.T) {
+	tests := []struct {
+		scheme      string
+		callNumbers []string
+	}{
+		{CallNumberDewey, []string{
+			"5",
+			"10",
+			"100",
+			"823.9",
+			"823.9 ROW",
+			"823.914",
+			"823.914 R6",
+			"823.914 R69",
+			"823.914 ROW",
+			"823.914 ROWL",
+			"824",
+		}},
+		{CallNumberLC, []string{
+			"Q1",
+			"Q11",
+			"QA1",
+			"QA76",
+			"QA76.73 .J38 2001",
+			"QA76.73 .J38 2010",
+			"QA76.73 .J4",
+			"QA76.9",
+			"QA761",
+			"QB5",
+		}},
+	}
+
+	for _, test := range tests {
+		previous := ""
+		for _, callNumber := range test.callNumbers {
+			_, key, err := CallNumberSortKey(callNumber, test.scheme)
+			if err != nil {
+				t.Errorf("CallNumberSortKey(%q, %q) returned error %v", callNumber, test.scheme, err)
+				continue
+			}
+			if key <= previous {
+				t.Errorf("CallNumberSortKey(%q) = %q, want it after %q", callNumber, key, previous)
+			}
+			previous = key
+		}
+	}
+}
+
+func TestCallNumberSortKeyScheme(t *testing.T) {
+	tests := []struct {
+		callNumber string
+		want       string
+	}{
+		{"823.914 ROW", CallNumberDewey},
+		{"005.133", CallNumberDewey},
+		{"QA76.73 .J38 2001", CallNumberLC},
+		{"qa76.73 .j38", CallNumberLC},
+	}
+
+	for _, test := range tests {
+		scheme, _, err := CallNumberSortKey(test.callNumber, "")
+		if err != nil {
+			t.Errorf("CallNumberSortKey(%q) returned error %v", test.callNumber, err)
+			continue
+		}
+		if scheme != test.want {
+			t.Errorf("CallNumberSortKey(%q) scheme = %q, want %q", test.callNumber, scheme, test.want)
+		}
+	}
+}
+
+// Case and spacing do not change where a book is shelved.
+func TestCallNumberSortKeyNormalizes(t *testing.T) {
+	_, want, err := CallNumberSortKey("QA76.73 .J38 2001", CallNumberLC)
+	if err != nil {
+		t.Fatalf("CallNumberSortKey returned error %v", err)
+	}
+
+	_, got, err := CallNumberSortKey("  qa76.73   .j38  2001 ", CallNumberLC)
+	if err != nil {
+		t.Fatalf("CallNumberSortKey returned error %v", err)
+	}
+	if got != want {
+		t.Errorf("sort key = %q, want %q", got, want)
+	}
+}
+
+func TestCallNumberSortKeyInvalid(t *testing.T) {
+	tests := []struct {
+		callNumber string
+		scheme     string
+		want       error
+	}{
+		{"", "", ErrInvalidCallNumber},
+		{"   ", "", ErrInvalidCallNumber},
+		{"1234", CallNumberDewey, ErrInvalidCallNumber},
+		{"823.914ROW", CallNumberDewey, ErrInvalidCallNumber},
+		{"QA76", CallNumberDewey, ErrInvalidCallNumber},
+		{"ABCD12", CallNumberLC, ErrInvalidCallNumber},
+		{"Q12345", CallNumberLC, ErrInvalidCallNumber},
+		{"823.914", CallNumberLC, ErrInvalidCallNumber},
+		{"QA76", "udc", ErrInvalidCallNumberScheme},
+	}
+
+	for _, test := range tests {
+		_, _, err := CallNumberSortKey(test.callNumber, test.scheme)
+		if !errors.Is(err, test.want) {
+			t.Errorf("CallNumberSortKey(%q, %q) error = %v, want %v", test.callNumber, test.scheme, err, test.want)
+		}
+	}
+}
@@ -14,4 +14,16 @@ func ErrorResponse(writer http.ResponseWriter, statusCode int, message string) {
 	}
 
 	json.NewEncoder(writer).Encode(res)
-}
\ No newline at end of file
+}
+
+func ErrorResponseWithReason(writer http.ResponseWriter, statusCode int, message string, reason interface{}) {
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(statusCode)
+
+	res := map[string]interface{}{
+		"message": message,
+		"reason":  reason,
+	}
+
+	json.NewEncoder(writer).Encode(res)
+}
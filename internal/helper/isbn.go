@@ -0,0 +1,89 @@
+package helper
+
+import (
+	"errors"
+	"strings"
+)
+
+var ErrInvalidISBN = errors.New("ISBN must be a valid ISBN-10 or ISBN-13")
+
+// NormalizeISBN validates an ISBN-10 or ISBN-13 (hyphens and spaces allowed)
+// and returns it as a bare ISBN-13, which is how ISBNs are stored.
+func NormalizeISBN(raw string) (string, error) {
+	isbn := strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(raw))
+
+	switch len(isbn) {
+	case 10:
+		if !validISBN10(isbn) {
+			return "", ErrInvalidISBN
+		}
+		isbn13 := "978" + isbn[:9]
+		return isbn13 + isbn13CheckDigit(isbn13), nil
+
+	case 13:
+		if !allDigits(isbn) || isbn13CheckDigit(isbn[:12]) != isbn[12:] {
+			return "", ErrInvalidISBN
+		}
+		return isbn, nil
+	}
+
+	return "", ErrInvalidISBN
+}
+
+// ISBN13To10 converts a normalized ISBN-13 back to ISBN-10. Only 978-prefixed
+// ISBNs have an ISBN-10 form.
+func ISBN13To10(isbn13 string) (string, bool) {
+	if len(isbn13) != 13 || !strings.HasPrefix(isbn13, "978") {
+		return "", false
+	}
+
+	body := isbn13[3:12]
+	sum := 0
+	for i, digit := range body {
+		sum += (10 - i) * int(digit-'0')
+	}
+
+	check := (11 - sum%11) % 11
+	if check == 10 {
+		return body + "X", true
+	}
+	return body + string(rune('0'+check)), true
+}
+
+func validISBN10(isbn string) bool {
+	sum := 0
+	for i, char := range isbn {
+		var value int
+		switch {
+		case char >= '0' && char <= '9':
+			value = int(char - '0')
+		case char == 'X' && i == 9:
+			value = 10
+		default:
+			return false
+		}
+		sum += (10 - i) * value
+	}
+	return sum%11 == 0
+}
+
+func isbn13CheckDigit(first12 string) string {
+	sum := 0
+	for i, digit := range first12 {
+		weight := 1
+		if i%2 == 1 {
+			weight = 3
+		}
+		sum += weight * int(digit-'0')
+	}
+	return string(rune('0' + (10-sum%10)%10))
+}
+
+func allDigits(text string) bool {
+	for _, char := range text {
+		if char < '0' || char > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,70 @@
+package helper
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNormalizeISBN(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want string
+	}{
+		{"0306406152", "9780306406157"},
+		{"0-306-40615-2", "9780306406157"},
+		{"978-0-306-40615-7", "9780306406157"},
+		{"978 0 306 40615 7", "9780306406157"},
+		{"0-8044-2957-X", "9780804429573"},
+		{"0-8044-2957-x", "9780804429573"},
+	}
+
+	for _, test := range tests {
+		got, err := NormalizeISBN(test.raw)
+		if err != nil {
+			t.Errorf("NormalizeISBN(%q) returned error %v", test.raw, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("NormalizeISBN(%q) = %q, want %q", test.raw, got, test.want)
+		}
+	}
+}
+
+func TestNormalizeISBNInvalid(t *testing.T) {
+	tests := []string{
+		"",
+		"0306406153",
+		"9780306406158",
+		"978030640615",
+		"X306406152",
+		"978030640615X",
+		"abcdefghij",
+	}
+
+	for _, raw := range tests {
+		_, err := NormalizeISBN(raw)
+		if !errors.Is(err, ErrInvalidISBN) {
+			t.Errorf("NormalizeISBN(%q) error = %v, want ErrInvalidISBN", raw, err)
+		}
+	}
+}
+
+func TestISBN13To10(t *testing.T) {
+	tests := []struct {
+		isbn13 string
+		want   string
+		ok     bool
+	}{
+		{"9780306406157", "0306406152", true},
+		{"9780804429573", "080442957X", true},
+		{"9791234567896", "", false},
+		{"978030640615", "", false},
+	}
+
+	for _, test := range tests {
+		got, ok := ISBN13To10(test.isbn13)
+		if got != test.want || ok != test.ok {
+			t.Errorf("ISBN13To10(%q) = %q, %v, want %q, %v", test.isbn13, got, ok, test.want, test.ok)
+		}
+	}
+}
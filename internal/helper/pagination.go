@@ -0,0 +1,79 @@
+package helper
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+const (
+	DefaultPageLimit = 20
+	MaxPageLimit     = 100
+)
+
+type Pagination struct {
+	Page  int
+	Limit int
+}
+
+func (pagination Pagination) Offset() int {
+	return (pagination.Page - 1) * pagination.Limit
+}
+
+func (pagination Pagination) TotalPages(total int) int {
+	return (total + pagination.Limit - 1) / pagination.Limit
+}
+
+// ParsePagination reads the page and limit query parameters, defaulting to
+// the first page of DefaultPageLimit rows and capping limit at MaxPageLimit.
+func ParsePagination(request *http.Request) (Pagination, error) {
+	pagination := Pagination{Page: 1, Limit: DefaultPageLimit}
+
+	if page := request.URL.Query().Get("page"); page != "" {
+		parsed, err := strconv.Atoi(page)
+		if err != nil || parsed < 1 {
+			return pagination, errors.New("page must be a positive integer")
+		}
+		pagination.Page = parsed
+	}
+
+	if limit := request.URL.Query().Get("limit"); limit != "" {
+		parsed, err := strconv.Atoi(limit)
+		if err != nil || parsed < 1 {
+			return pagination, errors.New("limit must be a positive integer")
+		}
+		if parsed > MaxPageLimit {
+			parsed = MaxPageLimit
+		}
+		pagination.Limit = parsed
+	}
+
+	return pagination, nil
+}
+
+// Links builds the next and previous page URLs for the current request,
+// keeping every other query parameter. Either is nil at the edge of the data.
+func (pagination Pagination) Links(request *http.Request, total int) (next *string, prev *string) {
+	pageURL := func(page int) *string {
+		query := url.Values{}
+		for key, values := range request.URL.Query() {
+			query[key] = values
+		}
+		query.Set("page", strconv.Itoa(page))
+		query.Set("limit", strconv.Itoa(pagination.Limit))
+
+		link := request.URL.Path + "?" + query.Encode()
+		return &link
+	}
+
+	if pagination.Page < pagination.TotalPages(total) {
+		next = pageURL(pagination.Page + 1)
+	}
+
+	if pagination.Page > 1 {
+		prev = pageURL(pagination.Page - 1)
+	}
+
+	return next, prev
+}
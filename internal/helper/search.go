@@ -0,0 +1,23 @@
+package helper
+
+import (
+	"strings"
+	"unicode"
+)
+
+// PrefixTSQuery turns free text into a to_tsquery expression where every word
+// must match as a prefix, e.g. "harry pot" becomes "harry:* & pot:*".
+// Anything that is not a letter or digit is dropped so user input can never
+// break the tsquery syntax.
+func PrefixTSQuery(text string) string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	terms := make([]string, 0, len(words))
+	for _, word := range words {
+		terms = append(terms, word+":*")
+	}
+
+	return strings.Join(terms, " & ")
+}
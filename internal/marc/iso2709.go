@@ -0,0 +1,216 @@
+package marc
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"unicode/utf8"
+)
+
+// ISO 2709 delimiters.
+const (
+	subfieldDelimiter = 0x1F
+	fieldTerminator   = 0x1E
+	recordTerminator  = 0x1D
+)
+
+const (
+	leaderLength         = 24
+	directoryEntryLength = 12
+	maxRecordLength      = 99999
+	// The directory holds field lengths in four digits.
+	maxFieldLength = 9999
+)
+
+var ErrRecordTooLong = errors.New("MARC record is longer than 99999 bytes")
+
+var ErrFieldTooLong = errors.New("MARC field is longer than 9999 bytes")
+
+// ParseError is a single malformed record. Reading can go on with the next
+// record after it.
+type ParseError struct {
+	Message string
+}
+
+func (err *ParseError) Error() string {
+	return err.Message
+}
+
+// Reader reads binary MARC21 (ISO 2709) records one at a time.
+type Reader struct {
+	reader *bufio.Reader
+}
+
+func NewReader(reader io.Reader) *Reader {
+	return &Reader{reader: bufio.NewReader(reader)}
+}
+
+// Read returns the next record, or io.EOF when there are no more.
+func (marcReader *Reader) Read() (*Record, error) {
+	data, err := marcReader.reader.ReadBytes(recordTerminator)
+	if err == io.EOF {
+		// Trailing line breaks after the last record are common.
+		if len(bytes.TrimSpace(data)) == 0 {
+			return nil, io.EOF
+		}
+		return nil, &ParseError{Message: "MARC record is missing its record terminator"}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return parseISO2709(bytes.TrimLeft(data, "\r\n"))
+}
+
+func parseISO2709(data []byte) (*Record, error) {
+	if len(data) < leaderLength {
+		return nil, &ParseError{Message: "MARC record is shorter than its leader"}
+	}
+
+	leader := string(data[:leaderLength])
+	baseAddress, err := strconv.Atoi(leader[12:17])
+	if err != nil || baseAddress <= leaderLength || baseAddress > len(data) {
+		return nil, &ParseError{Message: fmt.Sprintf("MARC leader has an invalid base address %q", leader[12:17])}
+	}
+
+	// Leader/09 is "a" for Unicode. Blank means MARC-8, which is not
+	// converted, so its diacritics would end up as invalid text.
+	if leader[9] != 'a' {
+		return nil, &ParseError{Message: "MARC record is not in UTF-8 (leader position 09 must be \"a\"), convert MARC-8 records to UTF-8 first"}
+	}
+	if !utf8.Valid(data) {
+		return nil, &ParseError{Message: "MARC record contains invalid UTF-8"}
+	}
+
+	record := &Record{Leader: leader}
+
+	directory := data[leaderLength : baseAddress-1]
+	if len(directory)%directoryEntryLength != 0 {
+		return nil, &ParseError{Message: "MARC directory has an invalid length"}
+	}
+
+	fields := data[baseAddress:]
+	for offset := 0; offset < len(directory); offset += directoryEntryLength {
+		entry := directory[offset : offset+directoryEntryLength]
+		tag := string(entry[:3])
+
+		length, lengthOk := parseDigits(entry[3:7])
+		start, startOk := parseDigits(entry[7:12])
+		if !lengthOk || !startOk || length < 1 || start+length > len(fields) {
+			return nil, &ParseError{Message: fmt.Sprintf("MARC directory entry for field %s is invalid", tag)}
+		}
+
+		// The length includes the field terminator.
+		value := fields[start : start+length-1]
+
+		if isControlTag(tag) {
+			record.ControlFields = append(record.ControlFields, ControlField{Tag: tag, Value: string(value)})
+			continue
+		}
+
+		if len(value) < 2 {
+			return nil, &ParseError{Message: fmt.Sprintf("MARC field %s is missing its indicators", tag)}
+		}
+
+		field := DataField{Tag: tag, Ind1: string(value[0]), Ind2: string(value[1])}
+		for _, subfield := range bytes.Split(value[2:], []byte{subfieldDelimiter}) {
+			if len(subfield) == 0 {
+				continue
+			}
+			field.Subfields = append(field.Subfields, Subfield{
+				Code:  string(subfield[0]),
+				Value: string(subfield[1:]),
+			})
+		}
+		record.DataFields = append(record.DataFields, field)
+	}
+
+	return record, nil
+}
+
+// WriteISO2709 writes record as binary MARC21, computing the directory and
+// the lengths and base address in the leader.
+func WriteISO2709(writer io.Writer, record *Record) error {
+	var directory bytes.Buffer
+	var fields bytes.Buffer
+
+	addField := func(tag string, value []byte) error {
+		if len(value)+1 > maxFieldLength {
+			return fmt.Errorf("%w: field %s", ErrFieldTooLong, tag)
+		}
+		fmt.Fprintf(&directory, "%s%04d%05d", tag, len(value)+1, fields.Len())
+		fields.Write(value)
+		fields.WriteByte(fieldTerminator)
+		return nil
+	}
+
+	for _, field := range record.ControlFields {
+		err := addField(field.Tag, []byte(field.Value))
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, field := range record.DataFields {
+		var value bytes.Buffer
+		value.WriteString(indicator(field.Ind1))
+		value.WriteString(indicator(field.Ind2))
+		for _, subfield := range field.Subfields {
+			value.WriteByte(subfieldDelimiter)
+			value.WriteString(subfield.Code)
+			value.WriteString(subfield.Value)
+		}
+		err := addField(field.Tag, value.Bytes())
+		if err != nil {
+			return err
+		}
+	}
+	directory.WriteByte(fieldTerminator)
+
+	baseAddress := leaderLength + directory.Len()
+	recordLength := baseAddress + fields.Len() + 1
+	if recordLength > maxRecordLength {
+		return ErrRecordTooLong
+	}
+
+	leader := []byte(record.Leader)
+	if len(leader) != leaderLength {
+		leader = []byte(DefaultLeader)
+	}
+	copy(leader[0:5], fmt.Sprintf("%05d", recordLength))
+	copy(leader[12:17], fmt.Sprintf("%05d", baseAddress))
+	// Records are always written in UTF-8.
+	leader[9] = 'a'
+
+	var output bytes.Buffer
+	output.Write(leader)
+	output.Write(directory.Bytes())
+	output.Write(fields.Bytes())
+	output.WriteByte(recordTerminator)
+
+	_, err := writer.Write(output.Bytes())
+	return err
+}
+
+// parseDigits parses a directory number, which is only ever digits. Atoi
+// would also accept signs, so "-0001" would pass as a start position.
+func parseDigits(digits []byte) (int, bool) {
+	value := 0
+	for _, digit := range digits {
+		if digit < '0' || digit > '9' {
+			return 0, false
+		}
+		value = value*10 + int(digit-'0')
+	}
+	return value, len(digits) > 0
+}
+
+func indicator(value string) string {
+	if len(value) != 1 {
+		return " "
+	}
+	return value
+}
@@ -0,0 +1,161 @@
+package marc
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func testRecord() *Record {
+	record := NewRecord()
+	record.AddControlField("001", "42")
+	record.AddControlField("008", "251023s2019    io            000 0 ind d")
+	record.AddDataField("020", " ", " ", "a", "9780306406157")
+	record.AddDataField("100", "1", " ", "a", "Gabriel García Márquez")
+	record.AddDataField("245", "1", "0", "a", "Cien años de soledad /", "b", "novela")
+	record.AddDataField("650", " ", "4", "a", "Fiction")
+	return record
+}
+
+func writeISO2709(t *testing.T, records ...*Record) []byte {
+	t.Helper()
+
+	var buffer bytes.Buffer
+	for _, record := range records {
+		err := WriteISO2709(&buffer, record)
+		if err != nil {
+			t.Fatalf("WriteISO2709 returned error %v", err)
+		}
+	}
+	return buffer.Bytes()
+}
+
+func TestISO2709RoundTrip(t *testing.T) {
+	first := testRecord()
+	second := NewRecord()
+	second.AddControlField("001", "43")
+	second.AddDataField("245", "0", "0", "a", "Bumi Manusia")
+
+	// Trailing line breaks after the last record are ignored.
+	data := append(writeISO2709(t, first, second), "\r\n"...)
+
+	reader := NewReader(bytes.NewReader(data))
+	for _, want := range []*Record{first, second} {
+		got, err := reader.Read()
+		if err != nil {
+			t.Fatalf("Read returned error %v", err)
+		}
+		if !reflect.DeepEqual(got.ControlFields, want.ControlFields) {
+			t.Errorf("control fields = %+v, want %+v", got.ControlFields, want.ControlFields)
+		}
+		if !reflect.DeepEqual(got.DataFields, want.DataFields) {
+			t.Errorf("data fields = %+v, want %+v", got.DataFields, want.DataFields)
+		}
+		if got.Leader[9] != 'a' {
+			t.Errorf("leader/09 = %q, want 'a'", got.Leader[9])
+		}
+	}
+
+	_, err := reader.Read()
+	if err != io.EOF {
+		t.Errorf("Read after the last record error = %v, want io.EOF", err)
+	}
+}
+
+func TestISO2709Leader(t *testing.T) {
+	data := writeISO2709(t, testRecord())
+
+	leader := string(data[:leaderLength])
+	if got, want := leader[0:5], fmt.Sprintf("%05d", len(data)); got != want {
+		t.Errorf("record length = %q, want %q", got, want)
+	}
+	if data[len(data)-1] != recordTerminator {
+		t.Errorf("record does not end with the record terminator")
+	}
+}
+
+func TestISO2709ParseErrors(t *testing.T) {
+	valid := writeISO2709(t, testRecord())
+
+	marc8 := bytes.Clone(valid)
+	marc8[9] = ' '
+
+	invalidUTF8 := bytes.Clone(valid)
+	index := bytes.Index(invalidUTF8, []byte("í"))
+	invalidUTF8[index+1] = 0xFF
+
+	badBaseAddress := bytes.Clone(valid)
+	copy(badBaseAddress[12:17], "99999")
+
+	// The first directory entry starts right after the leader.
+	negativeStart := bytes.Clone(valid)
+	copy(negativeStart[leaderLength+7:leaderLength+12], "-0001")
+
+	nonDigitLength := bytes.Clone(valid)
+	copy(nonDigitLength[leaderLength+3:leaderLength+7], " 1x3")
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"MARC-8 record", marc8},
+		{"invalid UTF-8", invalidUTF8},
+		{"invalid base address", badBaseAddress},
+		{"negative field start", negativeStart},
+		{"non-digit field length", nonDigitLength},
+		{"missing record terminator", valid[:len(valid)-1]},
+		{"shorter than the leader", append([]byte("00010nam"), recordTerminator)},
+	}
+
+	for _, test := range tests {
+		_, err := NewReader(bytes.NewReader(test.data)).Read()
+
+		var parseErr *ParseError
+		if !errors.As(err, &parseErr) {
+			t.Errorf("%s: Read error = %v, want a *ParseError", test.name, err)
+		}
+	}
+}
+
+func TestWriteISO2709TooLong(t *testing.T) {
+	record := NewRecord()
+	for range maxRecordLength / maxFieldLength {
+		record.AddDataField("520", " ", " ", "a", strings.Repeat("x", maxFieldLength-5))
+	}
+
+	err := WriteISO2709(io.Discard, record)
+	if !errors.Is(err, ErrRecordTooLong) {
+		t.Errorf("WriteISO2709 error = %v, want ErrRecordTooLong", err)
+	}
+}
+
+func TestWriteISO2709FieldTooLong(t *testing.T) {
+	record := NewRecord()
+	record.AddDataField("520", " ", " ", "a", strings.Repeat("x", 12000))
+
+	err := WriteISO2709(io.Discard, record)
+	if !errors.Is(err, ErrFieldTooLong) {
+		t.Errorf("WriteISO2709 error = %v, want ErrFieldTooLong", err)
+	}
+}
+
+// The longest field that fits the directory still round-trips.
+func TestISO2709LongestField(t *testing.T) {
+	// Indicators, the subfield delimiter and code, and the field terminator
+	// take 5 bytes.
+	summary := strings.Repeat("x", maxFieldLength-5)
+	record := NewRecord()
+	record.AddDataField("520", " ", " ", "a", summary)
+
+	got, err := NewReader(bytes.NewReader(writeISO2709(t, record))).Read()
+	if err != nil {
+		t.Fatalf("Read returned error %v", err)
+	}
+	if got.Subfield("520", "a") != summary {
+		t.Errorf("520$a has %d bytes, want %d", len(got.Subfield("520", "a")), len(summary))
+	}
+}
@@ -0,0 +1,128 @@
+package marc
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+)
+
+const marcXMLNamespace = "http://www.loc.gov/MARC21/slim"
+
+type xmlRecord struct {
+	XMLName       xml.Name          `xml:"record"`
+	Leader        string            `xml:"leader"`
+	ControlFields []xmlControlField `xml:"controlfield"`
+	DataFields    []xmlDataField    `xml:"datafield"`
+}
+
+type xmlControlField struct {
+	Tag   string `xml:"tag,attr"`
+	Value string `xml:",chardata"`
+}
+
+type xmlDataField struct {
+	Tag       string        `xml:"tag,attr"`
+	Ind1      string        `xml:"ind1,attr"`
+	Ind2      string        `xml:"ind2,attr"`
+	Subfields []xmlSubfield `xml:"subfield"`
+}
+
+type xmlSubfield struct {
+	Code  string `xml:"code,attr"`
+	Value string `xml:",chardata"`
+}
+
+// ReadXML reads every record of a MARCXML document, either a <collection>
+// or a single <record>.
+func ReadXML(reader io.Reader) ([]*Record, error) {
+	decoder := xml.NewDecoder(reader)
+
+	var records []*Record
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "record" {
+			continue
+		}
+
+		var parsed xmlRecord
+		err = decoder.DecodeElement(&parsed, &start)
+		if err != nil {
+			return nil, err
+		}
+
+		record := &Record{Leader: parsed.Leader}
+		for _, field := range parsed.ControlFields {
+			record.ControlFields = append(record.ControlFields, ControlField{Tag: field.Tag, Value: field.Value})
+		}
+		for _, field := range parsed.DataFields {
+			dataField := DataField{Tag: field.Tag, Ind1: field.Ind1, Ind2: field.Ind2}
+			for _, subfield := range field.Subfields {
+				dataField.Subfields = append(dataField.Subfields, Subfield{Code: subfield.Code, Value: subfield.Value})
+			}
+			record.DataFields = append(record.DataFields, dataField)
+		}
+		records = append(records, record)
+	}
+
+	if len(records) == 0 {
+		return nil, errors.New("MARCXML document has no records")
+	}
+
+	return records, nil
+}
+
+// XMLWriter streams records into a MARCXML <collection>. Close must be called
+// to end the document.
+type XMLWriter struct {
+	encoder *xml.Encoder
+}
+
+func NewXMLWriter(writer io.Writer) (*XMLWriter, error) {
+	_, err := io.WriteString(writer, xml.Header)
+	if err != nil {
+		return nil, err
+	}
+
+	encoder := xml.NewEncoder(writer)
+	err = encoder.EncodeToken(xml.StartElement{
+		Name: xml.Name{Local: "collection"},
+		Attr: []xml.Attr{{Name: xml.Name{Local: "xmlns"}, Value: marcXMLNamespace}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &XMLWriter{encoder: encoder}, nil
+}
+
+func (xmlWriter *XMLWriter) Write(record *Record) error {
+	output := xmlRecord{Leader: record.Leader}
+	for _, field := range record.ControlFields {
+		output.ControlFields = append(output.ControlFields, xmlControlField{Tag: field.Tag, Value: field.Value})
+	}
+	for _, field := range record.DataFields {
+		dataField := xmlDataField{Tag: field.Tag, Ind1: indicator(field.Ind1), Ind2: indicator(field.Ind2)}
+		for _, subfield := range field.Subfields {
+			dataField.Subfields = append(dataField.Subfields, xmlSubfield{Code: subfield.Code, Value: subfield.Value})
+		}
+		output.DataFields = append(output.DataFields, dataField)
+	}
+
+	return xmlWriter.encoder.Encode(output)
+}
+
+func (xmlWriter *XMLWriter) Close() error {
+	err := xmlWriter.encoder.EncodeToken(xml.EndElement{Name: xml.Name{Local: "collection"}})
+	if err != nil {
+		return err
+	}
+	return xmlWriter.encoder.Flush()
+}
@@ -0,0 +1,82 @@
+package marc
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMARCXMLRoundTrip(t *testing.T) {
+	first := testRecord()
+	second := NewRecord()
+	second.AddControlField("001", "43")
+	second.AddDataField("245", "0", "0", "a", "Tom & Jerry <3")
+
+	var buffer bytes.Buffer
+	writer, err := NewXMLWriter(&buffer)
+	if err != nil {
+		t.Fatalf("NewXMLWriter returned error %v", err)
+	}
+	for _, record := range []*Record{first, second} {
+		err = writer.Write(record)
+		if err != nil {
+			t.Fatalf("Write returned error %v", err)
+		}
+	}
+	err = writer.Close()
+	if err != nil {
+		t.Fatalf("Close returned error %v", err)
+	}
+
+	records, err := ReadXML(&buffer)
+	if err != nil {
+		t.Fatalf("ReadXML returned error %v", err)
+	}
+
+	want := []*Record{first, second}
+	if len(records) != len(want) {
+		t.Fatalf("ReadXML returned %d records, want %d", len(records), len(want))
+	}
+	for i := range want {
+		if !reflect.DeepEqual(records[i], want[i]) {
+			t.Errorf("record %d = %+v, want %+v", i, records[i], want[i])
+		}
+	}
+}
+
+func TestReadXMLSingleRecord(t *testing.T) {
+	document := `<?xml version="1.0" encoding="UTF-8"?>
+<record xmlns="http://www.loc.gov/MARC21/slim">
+  <leader>00000nam a2200000 i 4500</leader>
+  <controlfield tag="001">7</controlfield>
+  <datafield tag="245" ind1="1" ind2="0">
+    <subfield code="a">Bumi Manusia</subfield>
+  </datafield>
+</record>`
+
+	records, err := ReadXML(strings.NewReader(document))
+	if err != nil {
+		t.Fatalf("ReadXML returned error %v", err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("ReadXML returned %d records, want 1", len(records))
+	}
+	if got := records[0].Subfield("245", "a"); got != "Bumi Manusia" {
+		t.Errorf("245 $a = %q, want %q", got, "Bumi Manusia")
+	}
+}
+
+func TestReadXMLErrors(t *testing.T) {
+	tests := []string{
+		`<collection xmlns="http://www.loc.gov/MARC21/slim"></collection>`,
+		`<collection><record><leader>`,
+	}
+
+	for _, document := range tests {
+		_, err := ReadXML(strings.NewReader(document))
+		if err == nil {
+			t.Errorf("ReadXML(%q) returned no error", document)
+		}
+	}
+}
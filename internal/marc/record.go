@@ -0,0 +1,106 @@
+package marc
+
+import "strings"
+
+type Record struct {
+	Leader        string
+	ControlFields []ControlField
+	DataFields    []DataField
+}
+
+type ControlField struct {
+	Tag   string
+	Value string
+}
+
+type DataField struct {
+	Tag       string
+	Ind1      string
+	Ind2      string
+	Subfields []Subfield
+}
+
+type Subfield struct {
+	Code  string
+	Value string
+}
+
+// DefaultLeader is the leader of a new bibliographic record for a book,
+// encoded in UTF-8. Lengths and addresses are filled in when it is written.
+const DefaultLeader = "00000nam a2200000 i 4500"
+
+func NewRecord() *Record {
+	return &Record{Leader: DefaultLeader}
+}
+
+func (record *Record) AddControlField(tag string, value string) {
+	record.ControlFields = append(record.ControlFields, ControlField{Tag: tag, Value: value})
+}
+
+// AddDataField adds a field from alternating subfield codes and values.
+// Subfields with an empty value are left out, and so is the field if none
+// are left.
+func (record *Record) AddDataField(tag string, ind1 string, ind2 string, codesAndValues ...string) {
+	field := DataField{Tag: tag, Ind1: ind1, Ind2: ind2}
+	for i := 0; i+1 < len(codesAndValues); i += 2 {
+		if codesAndValues[i+1] == "" {
+			continue
+		}
+		field.Subfields = append(field.Subfields, Subfield{Code: codesAndValues[i], Value: codesAndValues[i+1]})
+	}
+
+	if len(field.Subfields) > 0 {
+		record.DataFields = append(record.DataFields, field)
+	}
+}
+
+func (record *Record) ControlField(tag string) string {
+	for _, field := range record.ControlFields {
+		if field.Tag == tag {
+			return field.Value
+		}
+	}
+	return ""
+}
+
+// Subfield returns the first subfield code of the first field tag that has
+// it, e.g. Subfield("245", "a") for the title.
+func (record *Record) Subfield(tag string, code string) string {
+	for _, field := range record.DataFields {
+		if field.Tag != tag {
+			continue
+		}
+		for _, subfield := range field.Subfields {
+			if subfield.Code == code {
+				return subfield.Value
+			}
+		}
+	}
+	return ""
+}
+
+// Subfields returns every subfield code of every field tag, in order.
+func (record *Record) Subfields(tag string, code string) []string {
+	var values []string
+	for _, field := range record.DataFields {
+		if field.Tag != tag {
+			continue
+		}
+		for _, subfield := range field.Subfields {
+			if subfield.Code == code {
+				values = append(values, subfield.Value)
+			}
+		}
+	}
+	return values
+}
+
+// TrimPunctuation strips the ISBD punctuation that cataloguers put at the end
+// of subfields, e.g. "Harry Potter /" becomes "Harry Potter".
+func TrimPunctuation(value string) string {
+	return strings.TrimSpace(strings.TrimRight(strings.TrimSpace(value), " /:;,="))
+}
+
+func isControlTag(tag string) bool {
+	return strings.HasPrefix(tag, "00")
+}
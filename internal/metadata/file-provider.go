@@ -0,0 +1,50 @@
+package metadata
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/faqq11/lib-management/internal/helper"
+)
+
+// FileProvider serves records from a JSON file holding an array of records,
+// so lookups work offline. Records may use ISBN-10 or ISBN-13.
+type FileProvider struct {
+	records map[string]Record
+}
+
+func NewFileProvider(path string) (*FileProvider, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []Record
+	err = json.Unmarshal(data, &records)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+
+	provider := &FileProvider{records: make(map[string]Record, len(records))}
+	for _, record := range records {
+		isbn13, err := helper.NormalizeISBN(record.ISBN)
+		if err != nil {
+			return nil, fmt.Errorf("parse %s: %q: %w", path, record.ISBN, err)
+		}
+		record.ISBN = isbn13
+		provider.records[isbn13] = record
+	}
+
+	return provider, nil
+}
+
+func (provider *FileProvider) Lookup(ctx context.Context, isbn13 string) (*Record, error) {
+	record, ok := provider.records[isbn13]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return &record, nil
+}
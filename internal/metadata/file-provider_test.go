@@ -0,0 +1,66 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestFileProviderLookup(t *testing.T) {
+	provider, err := NewFileProvider("testdata/metadata.json")
+	if err != nil {
+		t.Fatalf("NewFileProvider returned error %v", err)
+	}
+
+	tests := []struct {
+		isbn13 string
+		title  string
+	}{
+		{"9780747532699", "Harry Potter and the Philosopher's Stone"},
+		// Given as an ISBN-10 in the file.
+		{"9780131103627", "The C Programming Language"},
+	}
+
+	for _, test := range tests {
+		record, err := provider.Lookup(context.Background(), test.isbn13)
+		if err != nil {
+			t.Errorf("Lookup(%q) returned error %v", test.isbn13, err)
+			continue
+		}
+		if record.Title != test.title {
+			t.Errorf("Lookup(%q).Title = %q, want %q", test.isbn13, record.Title, test.title)
+		}
+		if record.ISBN != test.isbn13 {
+			t.Errorf("Lookup(%q).ISBN = %q, want the normalized ISBN-13", test.isbn13, record.ISBN)
+		}
+	}
+}
+
+func TestFileProviderLookupMiss(t *testing.T) {
+	provider, err := NewFileProvider("testdata/metadata.json")
+	if err != nil {
+		t.Fatalf("NewFileProvider returned error %v", err)
+	}
+
+	record, err := provider.Lookup(context.Background(), "9780306406157")
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("Lookup of an unknown ISBN error = %v, want ErrNotFound", err)
+	}
+	if record != nil {
+		t.Errorf("Lookup of an unknown ISBN returned %+v, want nil", record)
+	}
+}
+
+func TestNewFileProviderErrors(t *testing.T) {
+	tests := []string{
+		"testdata/missing.json",
+		"testdata/invalid-isbn.json",
+	}
+
+	for _, path := range tests {
+		_, err := NewFileProvider(path)
+		if err == nil {
+			t.Errorf("NewFileProvider(%q) returned no error", path)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+)
+
+var ErrNotFound = errors.New("no metadata found for this ISBN")
+
+type Record struct {
+	ISBN      string   `json:"isbn"`
+	Title     string   `json:"title"`
+	Authors   []string `json:"authors"`
+	Publisher *string  `json:"publisher"`
+	Year      *int     `json:"year"`
+	Subjects  []string `json:"subjects"`
+	CoverURL  *string  `json:"cover_url"`
+}
+
+// Provider looks up bibliographic metadata for a book. isbn13 is always a
+// normalized ISBN-13. Implementations return ErrNotFound when they have no
+// record for it.
+type Provider interface {
+	Lookup(ctx context.Context, isbn13 string) (*Record, error)
+}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+type Author struct {
+	ID        int       `db:"id" json:"id"`
+	Name      string    `db:"name" json:"name"`
+	Bio       *string   `db:"bio" json:"bio"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
+
+// BookContributor links a book to an author by id, or by name when the author
+// may not exist yet.
+type BookContributor struct {
+	AuthorID *int   `db:"author_id" json:"author_id"`
+	Name     string `db:"name" json:"name"`
+	Role     string `db:"role" json:"role"`
+}
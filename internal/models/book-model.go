@@ -6,7 +6,22 @@ type Book struct {
     ID int `db:"id" json:"id,omitempty"`
     Title string `db:"title" json:"title"`
     Author string `db:"author" json:"author"`
+    ISBN10 *string `db:"isbn10" json:"isbn10"`
+    ISBN13 *string `db:"isbn13" json:"isbn13"`
+    Description *string `db:"description" json:"description"`
+    PublicationYear *int `db:"publication_year" json:"publication_year"`
+    Publisher *string `db:"publisher" json:"publisher"`
+    Edition *string `db:"edition" json:"edition"`
+    Language *string `db:"language" json:"language"`
+    PageCount *int `db:"page_count" json:"page_count"`
+    WorkID *int `db:"work_id" json:"work_id"`
+    CallNumber *string `db:"call_number" json:"call_number"`
+    CallNumberScheme *string `db:"call_number_scheme" json:"call_number_scheme"`
+    CallNumberSort *string `db:"call_number_sort" json:"-"`
+    Room *string `db:"room" json:"room"`
+    Shelf *string `db:"shelf" json:"shelf"`
     CategoryID *int `db:"category_id" json:"category_id"`
     Stock int `db:"stock" json:"stock"`
+    Contributors []BookContributor `db:"-" json:"contributors,omitempty"`
     CreatedAt time.Time `db:"created_at" json:"created_at"`
 }
\ No newline at end of file
@@ -6,6 +6,12 @@ type Borrowing struct {
     ID int `db:"id" json:"id"`
     UserID int `db:"user_id" json:"user_id"`
     BookID int `db:"book_id" json:"book_id"`
+    ItemID *int `db:"item_id" json:"item_id"`
     BorrowedAt time.Time `db:"borrowed_at" json:"borrowed_at"`
+    DueAt time.Time `db:"due_at" json:"due_at"`
+    RenewalCount int `db:"renewal_count" json:"renewal_count"`
     ReturnedAt *time.Time `db:"returned_at" json:"returned_at"`
+    LostAt *time.Time `db:"lost_at" json:"lost_at"`
+    CheckedOutBy *int `db:"checked_out_by" json:"checked_out_by"`
+    CheckedInBy *int `db:"checked_in_by" json:"checked_in_by"`
 }
\ No newline at end of file
@@ -1,6 +1,10 @@
 package models
 
 type Category struct {
-	ID   int    `db:"id" json:"id"`
-	Name string `db:"name" json:"name"`
+	ID             int    `db:"id" json:"id"`
+	Name           string `db:"name" json:"name"`
+	ParentID       *int   `db:"parent_id" json:"parent_id"`
+	LoanPeriodDays *int   `db:"loan_period_days" json:"loan_period_days"`
+	FinePerDay     *int   `db:"fine_per_day" json:"fine_per_day"`
+	MaxFine        *int   `db:"max_fine" json:"max_fine"`
 }
\ No newline at end of file
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+type Fine struct {
+	ID          int       `db:"id" json:"id"`
+	BorrowingID int       `db:"borrowing_id" json:"borrowing_id"`
+	UserID      int       `db:"user_id" json:"user_id"`
+	Type        string    `db:"type" json:"type"`
+	Amount      int       `db:"amount" json:"amount"`
+	PaidAmount  int       `db:"paid_amount" json:"paid_amount"`
+	Status      string    `db:"status" json:"status"`
+	CreatedAt   time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt   time.Time `db:"updated_at" json:"updated_at"`
+}
+
+type FinePayment struct {
+	ID         int       `db:"id" json:"id"`
+	FineID     int       `db:"fine_id" json:"fine_id"`
+	Kind       string    `db:"kind" json:"kind"`
+	Amount     int       `db:"amount" json:"amount"`
+	Note       *string   `db:"note" json:"note"`
+	RecordedBy *int      `db:"recorded_by" json:"recorded_by"`
+	CreatedAt  time.Time `db:"created_at" json:"created_at"`
+}
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+type Hold struct {
+	ID        int        `db:"id" json:"id"`
+	UserID    int        `db:"user_id" json:"user_id"`
+	BookID    int        `db:"book_id" json:"book_id"`
+	ItemID    *int       `db:"item_id" json:"item_id"`
+	Status    string     `db:"status" json:"status"`
+	CreatedAt time.Time  `db:"created_at" json:"created_at"`
+	ReadyAt   *time.Time `db:"ready_at" json:"ready_at"`
+	ExpiresAt *time.Time `db:"expires_at" json:"expires_at"`
+}
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+type Item struct {
+	ID         int        `db:"id" json:"id"`
+	BookID     int        `db:"book_id" json:"book_id"`
+	Barcode    string     `db:"barcode" json:"barcode"`
+	Condition  string     `db:"condition" json:"condition"`
+	Status     string     `db:"status" json:"status"`
+	Location   *string    `db:"location" json:"location"`
+	AcquiredAt *time.Time `db:"acquired_at" json:"acquired_at"`
+	CreatedAt  time.Time  `db:"created_at" json:"created_at"`
+}
@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+type CirculationPolicy struct {
+	ID         int       `db:"id" json:"id"`
+	Rule       string    `db:"rule" json:"rule"`
+	Role       *string   `db:"role" json:"role"`
+	CategoryID *int      `db:"category_id" json:"category_id"`
+	MaxValue   int       `db:"max_value" json:"max_value"`
+	CreatedAt  time.Time `db:"created_at" json:"created_at"`
+}
@@ -3,20 +3,228 @@ package response
 import "time"
 
 type BookResponse struct {
-	ID         int       `db:"id" json:"id"`
-	Title      string    `db:"title" json:"title"`
-	Author     string    `db:"author" json:"author"`
-	CategoryID *int      `db:"category_id" json:"category_id"`
-	Category   *string   `db:"category" json:"category"`
-	Stock      int       `db:"stock" json:"stock"`
-	CreatedAt  time.Time `db:"created_at" json:"created_at"`
+	ID               int       `db:"id" json:"id"`
+	Title            string    `db:"title" json:"title"`
+	Author           string    `db:"author" json:"author"`
+	ISBN10           *string   `db:"isbn10" json:"isbn10"`
+	ISBN13           *string   `db:"isbn13" json:"isbn13"`
+	Description      *string   `db:"description" json:"description"`
+	PublicationYear  *int      `db:"publication_year" json:"publication_year"`
+	Publisher        *string   `db:"publisher" json:"publisher"`
+	Edition          *string   `db:"edition" json:"edition"`
+	Language         *string   `db:"language" json:"language"`
+	PageCount        *int      `db:"page_count" json:"page_count"`
+	WorkID           *int      `db:"work_id" json:"work_id"`
+	CallNumber       *string   `db:"call_number" json:"call_number"`
+	CallNumberScheme *string   `db:"call_number_scheme" json:"call_number_scheme"`
+	Room             *string   `db:"room" json:"room"`
+	Shelf            *string   `db:"shelf" json:"shelf"`
+	CategoryID       *int      `db:"category_id" json:"category_id"`
+	Category         *string   `db:"category" json:"category"`
+	Stock            int       `db:"stock" json:"stock"`
+	CreatedAt        time.Time `db:"created_at" json:"created_at"`
+
+	Contributors []ContributorResponse `db:"-" json:"contributors,omitempty"`
+	Tags         []string              `db:"-" json:"tags,omitempty"`
+}
+
+type TagCount struct {
+	ID    int    `db:"id" json:"id"`
+	Name  string `db:"name" json:"name"`
+	Count int    `db:"count" json:"count"`
+}
+
+type CategoryResponse struct {
+	ID             int    `db:"id" json:"id"`
+	Name           string `db:"name" json:"name"`
+	ParentID       *int   `db:"parent_id" json:"parent_id"`
+	LoanPeriodDays *int   `db:"loan_period_days" json:"loan_period_days"`
+	FinePerDay     *int   `db:"fine_per_day" json:"fine_per_day"`
+	MaxFine        *int   `db:"max_fine" json:"max_fine"`
+	BookCount      int    `db:"book_count" json:"book_count"`
+}
+
+type CategoryTreeNode struct {
+	CategoryResponse
+	TotalBookCount int                 `json:"total_book_count"`
+	Children       []*CategoryTreeNode `json:"children"`
+}
+
+type CategoryDetailResponse struct {
+	CategoryResponse
+	Books []BookResponse `db:"-" json:"books"`
+}
+
+type WorkResponse struct {
+	ID        int            `db:"id" json:"id"`
+	Title     string         `db:"title" json:"title"`
+	CreatedAt time.Time      `db:"created_at" json:"created_at"`
+	Editions  []BookResponse `db:"-" json:"editions"`
+}
+
+type ContributorResponse struct {
+	AuthorID int    `db:"author_id" json:"author_id"`
+	Name     string `db:"name" json:"name"`
+	Role     string `db:"role" json:"role"`
+}
+
+type AuthorResponse struct {
+	ID        int       `db:"id" json:"id"`
+	Name      string    `db:"name" json:"name"`
+	Bio       *string   `db:"bio" json:"bio"`
+	BookCount int       `db:"book_count" json:"book_count"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
+
+type AuthorBookResponse struct {
+	BookResponse
+	Role string `db:"role" json:"role"`
+}
+
+type BookSearchResult struct {
+	BookResponse
+	Rank    *float64 `db:"rank" json:"rank,omitempty"`
+	Snippet *string  `db:"snippet" json:"snippet,omitempty"`
+	Match   *string  `db:"match" json:"match,omitempty"`
+}
+
+type FacetCount struct {
+	Value *string `db:"value" json:"value"`
+	Label string  `db:"label" json:"label"`
+	Count int     `db:"count" json:"count"`
+}
+
+type SearchFacets struct {
+	Categories   []FacetCount `json:"categories"`
+	Authors      []FacetCount `json:"authors"`
+	Availability []FacetCount `json:"availability"`
+	Decades      []FacetCount `json:"decades"`
+	Languages    []FacetCount `json:"languages"`
+	Tags         []FacetCount `json:"tags"`
 }
+
+type BookSearchResponse struct {
+	Data   []BookSearchResult `json:"data"`
+	Facets SearchFacets       `json:"facets"`
+}
+
+type SearchSuggestion struct {
+	Text  string  `db:"text" json:"text"`
+	Field string  `db:"field" json:"field"`
+	Score float64 `db:"score" json:"score"`
+}
+
 type UserBorrowingResponse struct {
+	ID           int        `db:"id" json:"id"`
+	BookID       int        `db:"book_id" json:"book_id"`
+	BookTitle    string     `db:"book_title" json:"book_title"`
+	Author       string     `db:"author" json:"author"`
+	BorrowedAt   time.Time  `db:"borrowed_at" json:"borrowed_at"`
+	DueAt        time.Time  `db:"due_at" json:"due_at"`
+	RenewalCount int        `db:"renewal_count" json:"renewal_count"`
+	ReturnedAt   *time.Time `db:"returned_at" json:"returned_at"`
+	Barcode      *string    `db:"barcode" json:"barcode"`
+	Status       string     `db:"status" json:"status"`
+}
+
+type HoldResponse struct {
+	ID        int        `db:"id" json:"id"`
+	BookID    int        `db:"book_id" json:"book_id"`
+	BookTitle string     `db:"book_title" json:"book_title"`
+	Status    string     `db:"status" json:"status"`
+	Position  *int       `db:"position" json:"position"`
+	CreatedAt time.Time  `db:"created_at" json:"created_at"`
+	ReadyAt   *time.Time `db:"ready_at" json:"ready_at"`
+	ExpiresAt *time.Time `db:"expires_at" json:"expires_at"`
+}
+
+type FineResponse struct {
+	ID          int       `db:"id" json:"id"`
+	BorrowingID int       `db:"borrowing_id" json:"borrowing_id"`
+	UserID      int       `db:"user_id" json:"user_id"`
+	Username    string    `db:"username" json:"username"`
+	BookID      int       `db:"book_id" json:"book_id"`
+	BookTitle   string    `db:"book_title" json:"book_title"`
+	Type        string    `db:"type" json:"type"`
+	Amount      int       `db:"amount" json:"amount"`
+	PaidAmount  int       `db:"paid_amount" json:"paid_amount"`
+	Status      string    `db:"status" json:"status"`
+	CreatedAt   time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt   time.Time `db:"updated_at" json:"updated_at"`
+}
+
+type PolicyRefusal struct {
+	Rule     string `json:"rule"`
+	Message  string `json:"message"`
+	PolicyID *int   `json:"policy_id,omitempty"`
+	Limit    *int   `json:"limit,omitempty"`
+	Current  *int   `json:"current,omitempty"`
+}
+
+type ItemResponse struct {
 	ID         int        `db:"id" json:"id"`
 	BookID     int        `db:"book_id" json:"book_id"`
 	BookTitle  string     `db:"book_title" json:"book_title"`
-	Author     string     `db:"author" json:"author"`
-	BorrowedAt time.Time  `db:"borrowed_at" json:"borrowed_at"`
-	ReturnedAt *time.Time `db:"returned_at" json:"returned_at"`
+	Barcode    string     `db:"barcode" json:"barcode"`
+	Condition  string     `db:"condition" json:"condition"`
 	Status     string     `db:"status" json:"status"`
+	Location   *string    `db:"location" json:"location"`
+	AcquiredAt *time.Time `db:"acquired_at" json:"acquired_at"`
+	CreatedAt  time.Time  `db:"created_at" json:"created_at"`
+}
+
+type BorrowingResponse struct {
+	ID           int        `db:"id" json:"id"`
+	UserID       int        `db:"user_id" json:"user_id"`
+	Username     string     `db:"username" json:"username"`
+	BookID       int        `db:"book_id" json:"book_id"`
+	BookTitle    string     `db:"book_title" json:"book_title"`
+	Author       *string    `db:"author" json:"author"`
+	BorrowedAt   time.Time  `db:"borrowed_at" json:"borrowed_at"`
+	DueAt        time.Time  `db:"due_at" json:"due_at"`
+	RenewalCount int        `db:"renewal_count" json:"renewal_count"`
+	ReturnedAt   *time.Time `db:"returned_at" json:"returned_at"`
+	Barcode      *string    `db:"barcode" json:"barcode"`
+	CheckedOutBy *int       `db:"checked_out_by" json:"checked_out_by"`
+	CheckedInBy  *int       `db:"checked_in_by" json:"checked_in_by"`
+	Status       string     `db:"status" json:"status"`
+}
+
+type PaginatedResponse struct {
+	Data       interface{} `json:"data"`
+	Page       int         `json:"page"`
+	Limit      int         `json:"limit"`
+	Total      int         `json:"total"`
+	TotalPages int         `json:"total_pages"`
+	Next       *string     `json:"next"`
+	Prev       *string     `json:"prev"`
+}
+
+type CatalogDraft struct {
+	Title           string   `json:"title"`
+	Author          string   `json:"author"`
+	ISBN10          *string  `json:"isbn10"`
+	ISBN13          string   `json:"isbn13"`
+	PublicationYear *int     `json:"publication_year"`
+	CategoryID      *int     `json:"category_id"`
+	Publisher       *string  `json:"publisher"`
+	Subjects        []string `json:"subjects"`
+	CoverURL        *string  `json:"cover_url"`
+	ExistingBookID  *int     `json:"existing_book_id"`
+}
+
+type ImportRowResult struct {
+	Row    int      `json:"row"`
+	Status string   `json:"status"`
+	BookID *int     `json:"book_id"`
+	Title  string   `json:"title"`
+	Errors []string `json:"errors,omitempty"`
+}
+
+type ImportReport struct {
+	DryRun   bool              `json:"dry_run"`
+	Created  int               `json:"created"`
+	Updated  int               `json:"updated"`
+	Rejected int               `json:"rejected"`
+	Rows     []ImportRowResult `json:"rows"`
 }
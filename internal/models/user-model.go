@@ -7,5 +7,7 @@ type User struct {
     Username string `db:"username" json:"username"`
     Password string `db:"password,omitempty" json:"-"`
     Role string `db:"role" json:"role"`
+    Blocked bool `db:"blocked" json:"blocked"`
+    BlockedReason *string `db:"blocked_reason" json:"blocked_reason"`
     CreatedAt time.Time `db:"created_at" json:"created_at"`
 }
\ No newline at end of file
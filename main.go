@@ -5,8 +5,10 @@ import (
 	"net/http"
 	"os"
 
+	"github.com/faqq11/lib-management/internal/autocomplete"
 	"github.com/faqq11/lib-management/internal/db"
 	"github.com/faqq11/lib-management/internal/handlers"
+	"github.com/faqq11/lib-management/internal/metadata"
 	"github.com/faqq11/lib-management/internal/middleware"
 	"github.com/gorilla/mux"
 	"github.com/joho/godotenv"
@@ -21,12 +23,37 @@ func main() {
 	}
 	defer conn.Close()
 
+	autocompleteIndex := autocomplete.NewIndex()
+	err = autocompleteIndex.Load(conn)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var metadataProvider metadata.Provider
+	if metadataFile := os.Getenv("METADATA_FILE"); metadataFile != "" {
+		metadataProvider, err = metadata.NewFileProvider(metadataFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
 	router := mux.NewRouter()
 
 	userHandler := &handlers.UserHandler{DB: conn}
-	bookHandler := &handlers.BookHandler{DB: conn}
+	bookHandler := &handlers.BookHandler{DB: conn, Autocomplete: autocompleteIndex}
 	categoryHandler := &handlers.CategoryHandler{DB: conn}
+	itemHandler := &handlers.ItemHandler{DB: conn}
 	borrowHandler := &handlers.BorrowHandler{DB: conn}
+	holdHandler := &handlers.HoldHandler{DB: conn}
+	fineHandler := &handlers.FineHandler{DB: conn}
+	policyHandler := &handlers.PolicyHandler{DB: conn}
+	metadataHandler := &handlers.MetadataHandler{DB: conn, Provider: metadataProvider}
+	importHandler := &handlers.ImportHandler{DB: conn, Autocomplete: autocompleteIndex}
+	marcHandler := &handlers.MarcHandler{DB: conn}
+	exportHandler := &handlers.ExportHandler{DB: conn}
+	authorHandler := &handlers.AuthorHandler{DB: conn, Autocomplete: autocompleteIndex}
+	workHandler := &handlers.WorkHandler{DB: conn}
+	tagHandler := &handlers.TagHandler{DB: conn}
 
 	protected := router.PathPrefix("/api").Subrouter()
 	protected.Use(middleware.AuthMiddleware)
@@ -38,20 +65,77 @@ func main() {
 	router.HandleFunc("/api/login", userHandler.Login).Methods("POST")
 
 	adminOnly.HandleFunc("/create-book", bookHandler.InsertBook).Methods("POST")
+	adminOnly.HandleFunc("/cataloguing/isbn/{isbn}", metadataHandler.LookupISBN).Methods("GET")
+	adminOnly.HandleFunc("/books/import/csv", importHandler.ImportCSV).Methods("POST")
+	adminOnly.HandleFunc("/books/import/marc", importHandler.ImportMARC).Methods("POST")
+	adminOnly.HandleFunc("/books/{id}/marc", marcHandler.ExportBook).Methods("GET")
+	adminOnly.HandleFunc("/export/marc", marcHandler.ExportCatalog).Methods("GET")
+	adminOnly.HandleFunc("/export/books", exportHandler.ExportBooks).Methods("GET")
+	adminOnly.HandleFunc("/export/borrowings", exportHandler.ExportBorrowings).Methods("GET")
+	adminOnly.HandleFunc("/books/tags", tagHandler.TagBooks).Methods("POST")
+	adminOnly.HandleFunc("/books/tags/remove", tagHandler.UntagBooks).Methods("POST")
 	protected.HandleFunc("/books", bookHandler.GetAllBooks).Methods("GET")
 	protected.HandleFunc("/books/search", bookHandler.SearchBooks).Methods("GET")
+	protected.HandleFunc("/books/suggestions", bookHandler.GetSearchSuggestions).Methods("GET")
+	protected.HandleFunc("/books/autocomplete", bookHandler.AutocompleteBooks).Methods("GET")
+	protected.HandleFunc("/books/isbn/{isbn}", bookHandler.GetBookByISBN).Methods("GET")
 	protected.HandleFunc("/books/{id}", bookHandler.GetBookById).Methods("GET")
 	adminOnly.HandleFunc("/books/{id}", bookHandler.UpdateBook).Methods("PUT")
-	adminOnly.HandleFunc("/books/{id}/increase-stock", bookHandler.IncreaseStock).Methods("PUT")
-	adminOnly.HandleFunc("/books/{id}/decrease-stock", bookHandler.DecreaseStock).Methods("PUT")
 	adminOnly.HandleFunc("/books/{id}/delete", bookHandler.DeleteBook).Methods("DELETE")
+	protected.HandleFunc("/books/{id}/editions", workHandler.GetBookEditions).Methods("GET")
+
+	protected.HandleFunc("/tags", tagHandler.GetTagCloud).Methods("GET")
+	protected.HandleFunc("/shelf-list", bookHandler.GetShelfList).Methods("GET")
+
+	protected.HandleFunc("/works/{id}", workHandler.GetWorkById).Methods("GET")
+	adminOnly.HandleFunc("/works/{id}", workHandler.UpdateWork).Methods("PUT")
+
+	adminOnly.HandleFunc("/books/{id}/items", itemHandler.AddItems).Methods("POST")
+	adminOnly.HandleFunc("/books/{id}/items", itemHandler.GetBookItems).Methods("GET")
+	adminOnly.HandleFunc("/items/barcode/{barcode}", itemHandler.GetItemByBarcode).Methods("GET")
+	adminOnly.HandleFunc("/items/{id}", itemHandler.UpdateItem).Methods("PUT")
+
+	protected.HandleFunc("/authors", authorHandler.GetAuthors).Methods("GET")
+	protected.HandleFunc("/authors/{id}", authorHandler.GetAuthorById).Methods("GET")
+	protected.HandleFunc("/authors/{id}/books", authorHandler.GetAuthorBooks).Methods("GET")
+	adminOnly.HandleFunc("/authors", authorHandler.CreateAuthor).Methods("POST")
+	adminOnly.HandleFunc("/authors/{id}", authorHandler.UpdateAuthor).Methods("PUT")
+	adminOnly.HandleFunc("/authors/{id}", authorHandler.DeleteAuthor).Methods("DELETE")
 
 	adminOnly.HandleFunc("/create-category", categoryHandler.CreateCategory).Methods("POST")
 	adminOnly.HandleFunc("/delete-category/{id}", categoryHandler.DeleteCategory).Methods("DELETE")
+	protected.HandleFunc("/categories", categoryHandler.GetCategories).Methods("GET")
+	protected.HandleFunc("/categories/tree", categoryHandler.GetCategoryTree).Methods("GET")
+	protected.HandleFunc("/categories/{id}", categoryHandler.GetCategoryById).Methods("GET")
+	adminOnly.HandleFunc("/categories/{id}", categoryHandler.UpdateCategory).Methods("PUT")
+	adminOnly.HandleFunc("/categories/{id}/move", categoryHandler.MoveCategory).Methods("PUT")
+	adminOnly.HandleFunc("/categories/{id}/merge", categoryHandler.MergeCategory).Methods("POST")
 
 	protected.HandleFunc("/my-borrowings", borrowHandler.GetUserBorrowings).Methods("GET")
 	protected.HandleFunc("/books/{id}/borrow", borrowHandler.BorrowBook).Methods("POST")
 	protected.HandleFunc("/borrowings/{id}/return", borrowHandler.ReturnBook).Methods("PUT")
+	protected.HandleFunc("/borrowings/{id}/renew", borrowHandler.RenewBorrowing).Methods("POST")
+	adminOnly.HandleFunc("/loan-periods/{role}", borrowHandler.SetLoanPeriod).Methods("PUT")
+	adminOnly.HandleFunc("/borrowings", borrowHandler.GetAllBorrowings).Methods("GET")
+	adminOnly.HandleFunc("/circulation/checkout", borrowHandler.DeskCheckOut).Methods("POST")
+	adminOnly.HandleFunc("/circulation/checkin", borrowHandler.DeskCheckIn).Methods("POST")
+
+	protected.HandleFunc("/my-holds", holdHandler.GetUserHolds).Methods("GET")
+	protected.HandleFunc("/books/{id}/hold", holdHandler.PlaceHold).Methods("POST")
+	protected.HandleFunc("/holds/{id}/cancel", holdHandler.CancelHold).Methods("PUT")
+
+	protected.HandleFunc("/my-fines", fineHandler.GetUserFines).Methods("GET")
+	adminOnly.HandleFunc("/fines", fineHandler.GetAllFines).Methods("GET")
+	adminOnly.HandleFunc("/fines/{id}/pay", fineHandler.PayFine).Methods("POST")
+	adminOnly.HandleFunc("/fines/{id}/waive", fineHandler.WaiveFine).Methods("PUT")
+	adminOnly.HandleFunc("/borrowings/{id}/lost", fineHandler.MarkLost).Methods("PUT")
+
+	adminOnly.HandleFunc("/policies", policyHandler.GetPolicies).Methods("GET")
+	adminOnly.HandleFunc("/policies", policyHandler.CreatePolicy).Methods("POST")
+	adminOnly.HandleFunc("/policies/{id}", policyHandler.UpdatePolicy).Methods("PUT")
+	adminOnly.HandleFunc("/policies/{id}", policyHandler.DeletePolicy).Methods("DELETE")
+	adminOnly.HandleFunc("/users/{id}/block", userHandler.BlockUser).Methods("PUT")
+	adminOnly.HandleFunc("/users/{id}/unblock", userHandler.UnblockUser).Methods("PUT")
 
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -3,27 +3,377 @@ CREATE TABLE IF NOT EXISTS users (
   username TEXT UNIQUE NOT NULL,
   password TEXT NOT NULL,
   role TEXT NOT NULL DEFAULT 'user',
+  blocked BOOLEAN NOT NULL DEFAULT false,
+  blocked_reason TEXT,
   created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
 );
 
+-- Columns added after the first release, so existing databases catch up.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS blocked BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS blocked_reason TEXT;
+
 CREATE TABLE IF NOT EXISTS categories (
   id SERIAL PRIMARY KEY,
-  name TEXT UNIQUE NOT NULL
+  name TEXT UNIQUE NOT NULL,
+  parent_id INTEGER REFERENCES categories(id) ON DELETE RESTRICT CHECK (parent_id <> id),
+  loan_period_days INTEGER CHECK (loan_period_days > 0),
+  fine_per_day INTEGER CHECK (fine_per_day >= 0),
+  max_fine INTEGER CHECK (max_fine >= 0)
+);
+
+ALTER TABLE categories ADD COLUMN IF NOT EXISTS loan_period_days INTEGER CHECK (loan_period_days > 0);
+ALTER TABLE categories ADD COLUMN IF NOT EXISTS fine_per_day INTEGER CHECK (fine_per_day >= 0);
+ALTER TABLE categories ADD COLUMN IF NOT EXISTS max_fine INTEGER CHECK (max_fine >= 0);
+ALTER TABLE categories ADD COLUMN IF NOT EXISTS parent_id INTEGER REFERENCES categories(id) ON DELETE RESTRICT CHECK (parent_id <> id);
+
+CREATE INDEX IF NOT EXISTS categories_parent_idx ON categories (parent_id);
+
+-- A work groups the editions of the same book, e.g. a translation or a
+-- second edition from another publisher.
+CREATE TABLE IF NOT EXISTS works (
+  id SERIAL PRIMARY KEY,
+  title TEXT NOT NULL,
+  created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
 );
 
 CREATE TABLE IF NOT EXISTS books (
   id SERIAL PRIMARY KEY,
   title TEXT NOT NULL,
   author TEXT,
+  isbn10 TEXT,
+  isbn13 TEXT UNIQUE,
+  description TEXT,
+  publication_year INTEGER,
+  publisher TEXT,
+  edition TEXT,
+  language TEXT,
+  page_count INTEGER CHECK (page_count > 0),
+  work_id INTEGER REFERENCES works(id) ON DELETE SET NULL,
+  call_number TEXT,
+  call_number_scheme TEXT CHECK (call_number_scheme IN ('dewey', 'lc')),
+  call_number_sort TEXT,
+  room TEXT,
+  shelf TEXT,
   category_id INTEGER REFERENCES categories(id) ON DELETE SET NULL,
-  stock INTEGER NOT NULL DEFAULT 1,
+  search_vector tsvector,
+  created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+ALTER TABLE books ADD COLUMN IF NOT EXISTS description TEXT;
+ALTER TABLE books ADD COLUMN IF NOT EXISTS search_vector tsvector;
+ALTER TABLE books ADD COLUMN IF NOT EXISTS publication_year INTEGER;
+ALTER TABLE books ADD COLUMN IF NOT EXISTS isbn10 TEXT;
+ALTER TABLE books ADD COLUMN IF NOT EXISTS isbn13 TEXT UNIQUE;
+ALTER TABLE books ADD COLUMN IF NOT EXISTS publisher TEXT;
+ALTER TABLE books ADD COLUMN IF NOT EXISTS edition TEXT;
+ALTER TABLE books ADD COLUMN IF NOT EXISTS language TEXT;
+ALTER TABLE books ADD COLUMN IF NOT EXISTS page_count INTEGER CHECK (page_count > 0);
+ALTER TABLE books ADD COLUMN IF NOT EXISTS work_id INTEGER REFERENCES works(id) ON DELETE SET NULL;
+ALTER TABLE books ADD COLUMN IF NOT EXISTS call_number TEXT;
+ALTER TABLE books ADD COLUMN IF NOT EXISTS call_number_scheme TEXT CHECK (call_number_scheme IN ('dewey', 'lc'));
+ALTER TABLE books ADD COLUMN IF NOT EXISTS call_number_sort TEXT;
+ALTER TABLE books ADD COLUMN IF NOT EXISTS room TEXT;
+ALTER TABLE books ADD COLUMN IF NOT EXISTS shelf TEXT;
+
+CREATE INDEX IF NOT EXISTS books_work_idx ON books (work_id);
+
+-- call_number_sort is built by the API so that byte order is shelf order.
+CREATE INDEX IF NOT EXISTS books_shelf_order_idx ON books (lower(room), lower(shelf), call_number_sort COLLATE "C");
+
+-- Books with the same title and author start out as editions of one work.
+DO $$
+DECLARE
+  unassigned RECORD;
+  new_work_id INTEGER;
+BEGIN
+  FOR unassigned IN
+    SELECT min(id) AS id, lower(title) AS title_key, lower(coalesce(author, '')) AS author_key
+    FROM books
+    WHERE work_id IS NULL
+    GROUP BY 2, 3
+  LOOP
+    INSERT INTO works (title)
+    SELECT title FROM books WHERE id = unassigned.id
+    RETURNING id INTO new_work_id;
+
+    UPDATE books SET work_id = new_work_id
+    WHERE work_id IS NULL
+      AND lower(title) = unassigned.title_key
+      AND lower(coalesce(author, '')) = unassigned.author_key;
+  END LOOP;
+END
+$$;
+
+CREATE TABLE IF NOT EXISTS authors (
+  id SERIAL PRIMARY KEY,
+  name TEXT NOT NULL,
+  bio TEXT,
+  created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS authors_name_idx ON authors (lower(name));
+
+CREATE TABLE IF NOT EXISTS book_authors (
+  book_id INTEGER NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+  author_id INTEGER NOT NULL REFERENCES authors(id) ON DELETE RESTRICT,
+  role TEXT NOT NULL DEFAULT 'author' CHECK (role IN ('author', 'editor', 'translator', 'illustrator')),
+  position INTEGER NOT NULL DEFAULT 1,
+  PRIMARY KEY (book_id, author_id, role)
+);
+
+CREATE INDEX IF NOT EXISTS book_authors_author_idx ON book_authors (author_id);
+
+CREATE TABLE IF NOT EXISTS tags (
+  id SERIAL PRIMARY KEY,
+  name TEXT NOT NULL,
+  created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS tags_name_idx ON tags (lower(name));
+
+CREATE TABLE IF NOT EXISTS book_tags (
+  book_id INTEGER NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+  tag_id INTEGER NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+  created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+  PRIMARY KEY (book_id, tag_id)
+);
+
+CREATE INDEX IF NOT EXISTS book_tags_tag_idx ON book_tags (tag_id);
+
+-- books.author stays as the display string of the book's authors.
+INSERT INTO authors (name)
+SELECT DISTINCT author FROM books WHERE author <> ''
+ON CONFLICT ((lower(name))) DO NOTHING;
+
+INSERT INTO book_authors (book_id, author_id, role, position)
+SELECT b.id, a.id, 'author', 1
+FROM books b
+JOIN authors a ON lower(a.name) = lower(b.author)
+WHERE NOT EXISTS (SELECT 1 FROM book_authors ba WHERE ba.book_id = b.id);
+
+-- Title matches rank above author, category and description matches.
+-- Editors, translators and illustrators are searchable along with the authors.
+CREATE OR REPLACE FUNCTION books_search_vector_update() RETURNS trigger AS $$
+BEGIN
+  NEW.search_vector :=
+    setweight(to_tsvector('simple', coalesce(NEW.title, '')), 'A') ||
+    setweight(to_tsvector('simple', coalesce(NEW.author, '') || ' ' || coalesce((
+      SELECT string_agg(a.name, ' ')
+      FROM book_authors ba
+      JOIN authors a ON ba.author_id = a.id
+      WHERE ba.book_id = NEW.id AND ba.role <> 'author'
+    ), '')), 'B') ||
+    setweight(to_tsvector('simple', coalesce((SELECT name FROM categories WHERE id = NEW.category_id), '')), 'C') ||
+    setweight(to_tsvector('simple', coalesce(NEW.description, '')), 'D');
+  RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS books_search_vector_trigger ON books;
+CREATE TRIGGER books_search_vector_trigger
+  BEFORE INSERT OR UPDATE ON books
+  FOR EACH ROW EXECUTE FUNCTION books_search_vector_update();
+
+-- Renaming a category re-indexes its books.
+CREATE OR REPLACE FUNCTION categories_search_vector_refresh() RETURNS trigger AS $$
+BEGIN
+  IF NEW.name IS DISTINCT FROM OLD.name THEN
+    UPDATE books SET category_id = category_id WHERE category_id = NEW.id;
+  END IF;
+  RETURN NEW;
+END
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS categories_search_vector_trigger ON categories;
+CREATE TRIGGER categories_search_vector_trigger
+  AFTER UPDATE ON categories
+  FOR EACH ROW EXECUTE FUNCTION categories_search_vector_refresh();
+
+-- A work is removed once its last edition is deleted or moved elsewhere.
+CREATE OR REPLACE FUNCTION books_remove_empty_work() RETURNS trigger AS $$
+BEGIN
+  IF OLD.work_id IS NOT NULL AND (TG_OP = 'DELETE' OR NEW.work_id IS DISTINCT FROM OLD.work_id) THEN
+    DELETE FROM works w
+    WHERE w.id = OLD.work_id
+      AND NOT EXISTS (SELECT 1 FROM books WHERE work_id = OLD.work_id);
+  END IF;
+  RETURN NULL;
+END
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS books_remove_empty_work_trigger ON books;
+CREATE TRIGGER books_remove_empty_work_trigger
+  AFTER UPDATE OF work_id OR DELETE ON books
+  FOR EACH ROW EXECUTE FUNCTION books_remove_empty_work();
+
+-- Index the books that were added before the trigger existed.
+UPDATE books SET title = title WHERE search_vector IS NULL;
+
+CREATE INDEX IF NOT EXISTS books_search_vector_idx ON books USING GIN (search_vector);
+
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX IF NOT EXISTS books_title_trgm_idx ON books USING GIN (title gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS books_author_trgm_idx ON books USING GIN (author gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS authors_name_trgm_idx ON authors USING GIN (name gin_trgm_ops);
+
+CREATE SEQUENCE IF NOT EXISTS item_barcode_seq;
+
+CREATE TABLE IF NOT EXISTS items (
+  id SERIAL PRIMARY KEY,
+  book_id INTEGER NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+  barcode TEXT UNIQUE NOT NULL DEFAULT ('LIB' || lpad(nextval('item_barcode_seq')::text, 8, '0')),
+  condition TEXT NOT NULL DEFAULT 'good',
+  status TEXT NOT NULL DEFAULT 'available',
+  location TEXT,
+  acquired_at DATE DEFAULT CURRENT_DATE,
   created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
 );
 
+CREATE INDEX IF NOT EXISTS items_book_status_idx ON items (book_id, status);
+
 CREATE TABLE IF NOT EXISTS borrowings (
   id SERIAL PRIMARY KEY,
   user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
   book_id INTEGER REFERENCES books(id) ON DELETE CASCADE,
+  item_id INTEGER REFERENCES items(id) ON DELETE SET NULL,
   borrowed_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
-  returned_at TIMESTAMP WITH TIME ZONE
-);
\ No newline at end of file
+  due_at TIMESTAMP WITH TIME ZONE NOT NULL,
+  renewal_count INTEGER NOT NULL DEFAULT 0,
+  returned_at TIMESTAMP WITH TIME ZONE,
+  lost_at TIMESTAMP WITH TIME ZONE,
+  checked_out_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+  checked_in_by INTEGER REFERENCES users(id) ON DELETE SET NULL
+);
+
+ALTER TABLE borrowings ADD COLUMN IF NOT EXISTS due_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE borrowings ADD COLUMN IF NOT EXISTS renewal_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE borrowings ADD COLUMN IF NOT EXISTS lost_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE borrowings ADD COLUMN IF NOT EXISTS item_id INTEGER REFERENCES items(id) ON DELETE SET NULL;
+ALTER TABLE borrowings ADD COLUMN IF NOT EXISTS checked_out_by INTEGER REFERENCES users(id) ON DELETE SET NULL;
+ALTER TABLE borrowings ADD COLUMN IF NOT EXISTS checked_in_by INTEGER REFERENCES users(id) ON DELETE SET NULL;
+
+CREATE TABLE IF NOT EXISTS holds (
+  id SERIAL PRIMARY KEY,
+  user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+  book_id INTEGER REFERENCES books(id) ON DELETE CASCADE,
+  item_id INTEGER REFERENCES items(id) ON DELETE SET NULL,
+  status TEXT NOT NULL DEFAULT 'waiting',
+  created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+  ready_at TIMESTAMP WITH TIME ZONE,
+  expires_at TIMESTAMP WITH TIME ZONE
+);
+
+ALTER TABLE holds ADD COLUMN IF NOT EXISTS ready_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE holds ADD COLUMN IF NOT EXISTS expires_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE holds ADD COLUMN IF NOT EXISTS item_id INTEGER REFERENCES items(id) ON DELETE SET NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS holds_active_user_book_idx
+  ON holds (user_id, book_id) WHERE status IN ('waiting', 'ready');
+
+CREATE INDEX IF NOT EXISTS holds_queue_idx
+  ON holds (book_id, created_at, id) WHERE status = 'waiting';
+
+-- books.stock counted the copies on the shelf. Turn it into items, plus one
+-- item for every copy that is out on loan, lost or waiting on a ready hold.
+DO $$
+DECLARE
+  open_loan RECORD;
+  ready_hold RECORD;
+  new_item_id INTEGER;
+BEGIN
+  IF NOT EXISTS (
+    SELECT 1 FROM information_schema.columns
+    WHERE table_schema = current_schema() AND table_name = 'books' AND column_name = 'stock'
+  ) THEN
+    RETURN;
+  END IF;
+
+  INSERT INTO items (book_id)
+  SELECT b.id FROM books b, generate_series(1, b.stock);
+
+  FOR open_loan IN
+    SELECT id, book_id, lost_at FROM borrowings WHERE returned_at IS NULL AND item_id IS NULL
+  LOOP
+    INSERT INTO items (book_id, status)
+    VALUES (open_loan.book_id, CASE WHEN open_loan.lost_at IS NULL THEN 'on_loan' ELSE 'lost' END)
+    RETURNING id INTO new_item_id;
+
+    UPDATE borrowings SET item_id = new_item_id WHERE id = open_loan.id;
+  END LOOP;
+
+  FOR ready_hold IN
+    SELECT id, book_id FROM holds WHERE status = 'ready' AND item_id IS NULL
+  LOOP
+    INSERT INTO items (book_id, status)
+    VALUES (ready_hold.book_id, 'on_hold')
+    RETURNING id INTO new_item_id;
+
+    UPDATE holds SET item_id = new_item_id WHERE id = ready_hold.id;
+  END LOOP;
+
+  ALTER TABLE books DROP COLUMN stock;
+END
+$$;
+
+CREATE TABLE IF NOT EXISTS loan_periods (
+  role TEXT PRIMARY KEY,
+  days INTEGER NOT NULL CHECK (days > 0)
+);
+
+INSERT INTO loan_periods (role, days) VALUES ('user', 14), ('admin', 30)
+ON CONFLICT (role) DO NOTHING;
+
+-- Loans made before due dates existed are due one loan period after they
+-- were borrowed.
+UPDATE borrowings br
+SET due_at = coalesce(br.borrowed_at, now()) + make_interval(days => coalesce((
+  SELECT lp.days FROM users u JOIN loan_periods lp ON lp.role = u.role WHERE u.id = br.user_id
+), 14))
+WHERE br.due_at IS NULL;
+
+ALTER TABLE borrowings ALTER COLUMN due_at SET NOT NULL;
+
+CREATE TABLE IF NOT EXISTS fines (
+  id SERIAL PRIMARY KEY,
+  borrowing_id INTEGER NOT NULL REFERENCES borrowings(id) ON DELETE CASCADE,
+  user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+  type TEXT NOT NULL,
+  amount INTEGER NOT NULL DEFAULT 0,
+  paid_amount INTEGER NOT NULL DEFAULT 0,
+  -- Overdue charges from loan periods that ended in a renewal.
+  accrued_amount INTEGER NOT NULL DEFAULT 0,
+  status TEXT NOT NULL DEFAULT 'outstanding',
+  created_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+  updated_at TIMESTAMP WITH TIME ZONE DEFAULT now(),
+  UNIQUE (borrowing_id, type)
+);
+
+ALTER TABLE fines ADD COLUMN IF NOT EXISTS accrued_amount INTEGER NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS fine_payments (
+  id SERIAL PRIMARY KEY,
+  fine_id INTEGER NOT NULL REFERENCES fines(id) ON DELETE CASCADE,
+  kind TEXT NOT NULL,
+  amount INTEGER NOT NULL,
+  note TEXT,
+  recorded_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+  created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+CREATE TABLE IF NOT EXISTS circulation_policies (
+  id SERIAL PRIMARY KEY,
+  rule TEXT NOT NULL,
+  role TEXT,
+  category_id INTEGER REFERENCES categories(id) ON DELETE CASCADE,
+  max_value INTEGER NOT NULL CHECK (max_value >= 0),
+  created_at TIMESTAMP WITH TIME ZONE DEFAULT now()
+);
+
+INSERT INTO circulation_policies (rule, role, max_value)
+SELECT 'max_loans', 'user', 5
+WHERE NOT EXISTS (SELECT 1 FROM circulation_policies);
+
+INSERT INTO circulation_policies (rule, max_value)
+SELECT 'max_outstanding_fines', 20000
+WHERE NOT EXISTS (SELECT 1 FROM circulation_policies WHERE rule = 'max_outstanding_fines');